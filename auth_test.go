@@ -0,0 +1,141 @@
+package oclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBearerToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithBearerToken("secret"))
+	require.NoError(t, err)
+	assert.NoError(t, c.ReportStats(report))
+
+	c, err = NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithBearerToken("guess"))
+	require.NoError(t, err)
+	err = c.ReportStats(report)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.False(t, errors.Is(err, ErrReportRejected))
+}
+
+func TestHMACSigner(t *testing.T) {
+	verifier := &HMACSigner{KeyID: "k1", Key: []byte("shared")}
+	var verifyErr error
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = verifier.Verify(r, body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithHMACKey("k1", []byte("shared")))
+	require.NoError(t, err)
+	require.NoError(t, c.ReportStats(report))
+	assert.NoError(t, verifyErr)
+
+	c, err = NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithHMACKey("k1", []byte("forged")))
+	require.NoError(t, err)
+	require.NoError(t, c.ReportStats(report))
+	assert.Error(t, verifyErr)
+}
+
+func TestHMACSignerRejectsStaleSignatures(t *testing.T) {
+	body := []byte(`{}`)
+	signer := &HMACSigner{KeyID: "k1", Key: []byte("shared"), now: func() time.Time { return time.Unix(1000, 0) }}
+	req := httptest.NewRequest(http.MethodPost, "/scorings/", nil)
+	require.NoError(t, signer.Authenticate(req, body))
+
+	verifier := &HMACSigner{KeyID: "k1", Key: []byte("shared"), now: func() time.Time { return time.Unix(1000+60, 0) }}
+	assert.NoError(t, verifier.Verify(req, body))
+	assert.Error(t, verifier.Verify(req, []byte(`{"tampered":true}`)))
+
+	verifier.now = func() time.Time { return time.Unix(1000+3600, 0) }
+	assert.Error(t, verifier.Verify(req, body))
+}
+
+func TestClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	clientCert := writeSelfSignedCert(t, certFile, keyFile)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+	serverPool := x509.NewCertPool()
+	serverPool.AddCert(srv.Certificate())
+
+	anonymous, err := NewOracleClient(WithBaseURL(srv.URL), WithNetwork(NetworkIP),
+		WithTLSConfig(&tls.Config{RootCAs: serverPool}))
+	require.NoError(t, err)
+	_, err = anonymous.FetchScoresContext(context.Background(), server.ScoringQuery{})
+	assert.Error(t, err)
+
+	authenticated, err := NewOracleClient(WithBaseURL(srv.URL), WithNetwork(NetworkIP),
+		WithTLSConfig(&tls.Config{RootCAs: serverPool}), WithClientCertificate(certFile, keyFile))
+	require.NoError(t, err)
+	_, err = authenticated.FetchScoresContext(context.Background(), server.ScoringQuery{})
+	assert.NoError(t, err)
+}
+
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "oracle client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	rawKey, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey}), 0600))
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
@@ -0,0 +1,326 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io"
+	"net/http"
+	"net/url"
+	"oclient/cbor"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// capabilitiesPath describes the API versions, URL layout, encodings and services a path oracle offers.
+	capabilitiesPath = "/capabilities/"
+	// capabilitiesTimeout bounds the query of the capabilities, which does not end with the request waiting for it.
+	capabilitiesTimeout = 10 * time.Second
+	// capabilitiesBackoff is the time a failed query of the capabilities is cached, doubling with every further
+	// failure up to capabilitiesMaxBackoff.
+	capabilitiesBackoff    = time.Second
+	capabilitiesMaxBackoff = time.Minute
+)
+
+// APIVersions are the versions of the path oracle API this client speaks.
+var APIVersions = []int{1}
+
+// APIPaths is the URL layout of a path oracle, relative to its base URL.
+type APIPaths struct {
+	Scorings  string `json:"scorings,omitempty"`
+	Subscribe string `json:"subscribe,omitempty"`
+	// Reports is a template of the URL reports are posted to, {isd}, {as} and {fp} are replaced by the
+	// destination and fingerprint of the path.
+	Reports  string `json:"reports,omitempty"`
+	Batch    string `json:"batch,omitempty"`
+	Services string `json:"services,omitempty"`
+}
+
+// defaultPaths is the layout of version 1 of the API, used by path oracles without a capabilities endpoint.
+var defaultPaths = APIPaths{
+	Scorings:  "/scorings/",
+	Subscribe: "/scorings/subscribe/",
+	Reports:   "/reports/{isd}/{as}/{fp}/",
+	Batch:     "/reports/",
+	Services:  "/services/",
+}
+
+// Capabilities describe the API offered by a path oracle.
+type Capabilities struct {
+	// Versions are the supported API versions.
+	Versions []int `json:"versions"`
+	// Paths overrides the URL layout of the API version. Missing paths keep their default.
+	Paths APIPaths `json:"paths"`
+	// Encodings are the media types accepted in request bodies, e.g. application/cbor.
+	Encodings []string `json:"encodings,omitempty"`
+	// ContentEncodings are the accepted content encodings of request bodies, e.g. gzip.
+	ContentEncodings []string `json:"content_encodings,omitempty"`
+	// Services are the scoring services offered. Empty if unknown.
+	Services []services.ServiceName `json:"services,omitempty"`
+	// Legacy is set for path oracles without a capabilities endpoint, which are assumed to speak version 1.
+	Legacy bool `json:"-"`
+}
+
+// legacyCapabilities are assumed for path oracles predating the capabilities endpoint.
+var legacyCapabilities = Capabilities{Versions: []int{1}, Paths: defaultPaths, Legacy: true}
+
+// SupportsService reports whether the path oracle offers the scoring service, true if its services are unknown.
+func (c *Capabilities) SupportsService(service services.ServiceName) bool {
+	if len(c.Services) == 0 {
+		return true
+	}
+	for _, s := range c.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompatibleError is returned if a path oracle does not offer any API version this client speaks. It matches
+// ErrIncompatibleOracle.
+type IncompatibleError struct {
+	URL string
+	// Versions are the API versions offered by the path oracle.
+	Versions []int
+	// Reason describes what else is incompatible, if the versions match.
+	Reason string
+}
+
+func (e *IncompatibleError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%v: %s at %s", ErrIncompatibleOracle, e.Reason, e.URL)
+	}
+	return fmt.Sprintf("%v: %s offers API versions %v, client supports %v", ErrIncompatibleOracle, e.URL,
+		e.Versions, APIVersions)
+}
+
+func (e *IncompatibleError) Is(target error) bool {
+	return target == ErrIncompatibleOracle
+}
+
+// Capabilities returns the capabilities of the preferred path oracle, querying them if they are not known yet.
+// It fails with an IncompatibleError if the path oracle speaks none of the APIVersions.
+func (c *OracleClient) Capabilities(ctx context.Context) (*Capabilities, error) {
+	eps := c.candidates()
+	if len(eps) == 0 {
+		return nil, ErrNoOracle
+	}
+	return c.fetchCapabilities(ctx, eps[0])
+}
+
+// capabilities returns the capabilities of the endpoint if discovery is enabled by WithCapabilities, otherwise
+// the legacy capabilities.
+func (c *OracleClient) capabilities(ctx context.Context, ep *endpoint) (*Capabilities, error) {
+	if !c.discoverCaps {
+		return &legacyCapabilities, nil
+	}
+	return c.fetchCapabilities(ctx, ep)
+}
+
+// fetchCapabilities queries the capabilities of the endpoint once. Compatible and incompatible capabilities are
+// cached, failed queries are repeated by the first request after a backoff. Concurrent callers share a single
+// query, which is detached from their contexts.
+func (c *OracleClient) fetchCapabilities(ctx context.Context, ep *endpoint) (*Capabilities, error) {
+	if caps, err := ep.cachedCapabilities(); caps != nil || err != nil {
+		return caps, err
+	}
+	err := ep.capsFlights.do(ctx, capabilitiesPath, func() error {
+		if caps, err := ep.cachedCapabilities(); caps != nil || err != nil {
+			// cached by a query which ended meanwhile
+			return err
+		}
+		caps, err := c.queryCapabilities(ep)
+		var incompatible *IncompatibleError
+		if err != nil && !errors.As(err, &incompatible) {
+			ep.failedCapabilities(err)
+			return err
+		}
+		ep.capsMutex.Lock()
+		ep.caps, ep.capsErr, ep.capsFailure = caps, err, nil
+		ep.capsMutex.Unlock()
+		if err != nil {
+			return err
+		}
+		c.logger.Infow("path oracle capabilities", "endpoint", ep.baseURL, "versions", caps.Versions,
+			"legacy", caps.Legacy, "encodings", caps.Encodings, "services", caps.Services)
+		if c.encoding != nil && !caps.Legacy {
+			ep.setNegotiated(contains(caps.Encodings, cbor.ContentType), contains(caps.ContentEncodings, gzipEncoding))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ep.cachedCapabilities()
+}
+
+// queryCapabilities requests the capabilities of the endpoint like any other request, bounded by
+// capabilitiesTimeout.
+func (c *OracleClient) queryCapabilities(ep *endpoint) (*Capabilities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), capabilitiesTimeout)
+	defer cancel()
+	res, err := c.request(ctx, ep, http.MethodGet, opCapabilities, ep.url(capabilitiesPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}()
+
+	var caps Capabilities
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed:
+		caps = legacyCapabilities
+	case res.StatusCode >= 200 && res.StatusCode <= 299:
+		if err := decode(res, &caps); err != nil {
+			return nil, fmt.Errorf("could not decode path oracle capabilities: %w", err)
+		}
+		if err := c.checkCapabilities(ep, &caps); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, checkResponse(res, nil)
+	}
+	return &caps, nil
+}
+
+// cachedCapabilities returns the cached capabilities of the endpoint, or the error if they are incompatible or
+// their last query failed less than the backoff ago. Both are nil if the capabilities are not known yet.
+func (e *endpoint) cachedCapabilities() (*Capabilities, error) {
+	e.capsMutex.Lock()
+	defer e.capsMutex.Unlock()
+	if e.caps == nil && e.capsErr == nil && e.capsFailure != nil && time.Now().Before(e.capsRetryAt) {
+		return nil, e.capsFailure
+	}
+	return e.caps, e.capsErr
+}
+
+// failedCapabilities caches the error of a failed query of the capabilities for an exponentially growing backoff.
+func (e *endpoint) failedCapabilities(err error) {
+	e.capsMutex.Lock()
+	defer e.capsMutex.Unlock()
+	backoff := capabilitiesMaxBackoff
+	if e.capsFailures < 16 && capabilitiesBackoff<<e.capsFailures < capabilitiesMaxBackoff {
+		backoff = capabilitiesBackoff << e.capsFailures
+	}
+	e.capsFailures++
+	e.capsFailure, e.capsRetryAt = err, time.Now().Add(backoff)
+}
+
+// checkCapabilities verifies the capabilities of the endpoint are compatible and completes their paths.
+func (c *OracleClient) checkCapabilities(ep *endpoint, caps *Capabilities) error {
+	compatible := false
+	for _, v := range caps.Versions {
+		for _, supported := range APIVersions {
+			compatible = compatible || v == supported
+		}
+	}
+	if !compatible {
+		return &IncompatibleError{URL: ep.baseURL.String(), Versions: caps.Versions}
+	}
+
+	for _, p := range []struct {
+		path *string
+		def  string
+	}{
+		{&caps.Paths.Scorings, defaultPaths.Scorings},
+		{&caps.Paths.Subscribe, defaultPaths.Subscribe},
+		{&caps.Paths.Reports, defaultPaths.Reports},
+		{&caps.Paths.Batch, defaultPaths.Batch},
+		{&caps.Paths.Services, defaultPaths.Services},
+	} {
+		if *p.path == "" {
+			*p.path = p.def
+		}
+		if !strings.HasPrefix(*p.path, "/") {
+			return &IncompatibleError{URL: ep.baseURL.String(), Versions: caps.Versions,
+				Reason: fmt.Sprintf("path %q is not relative to the base URL", *p.path)}
+		}
+	}
+	if !strings.Contains(caps.Paths.Reports, "{fp}") {
+		return &IncompatibleError{URL: ep.baseURL.String(), Versions: caps.Versions,
+			Reason: fmt.Sprintf("reports path %q misses the path fingerprint", caps.Paths.Reports)}
+	}
+	return nil
+}
+
+// paths returns the URL layout of the endpoint.
+func (c *OracleClient) paths(ctx context.Context, ep *endpoint) (APIPaths, error) {
+	caps, err := c.capabilities(ctx, ep)
+	if err != nil {
+		return APIPaths{}, err
+	}
+	return caps.Paths, nil
+}
+
+// cachedPaths returns the URL layout of the endpoint if its capabilities are known, the default otherwise.
+func (e *endpoint) cachedPaths() APIPaths {
+	e.capsMutex.Lock()
+	defer e.capsMutex.Unlock()
+	if e.caps == nil {
+		return defaultPaths
+	}
+	return e.caps.Paths
+}
+
+// advertisedEncodings reports whether the capabilities of the endpoint list its encodings, which then take
+// precedence over the headers of its responses.
+func (e *endpoint) advertisedEncodings() bool {
+	e.capsMutex.Lock()
+	defer e.capsMutex.Unlock()
+	return e.caps != nil && (len(e.caps.Encodings) > 0 || len(e.caps.ContentEncodings) > 0)
+}
+
+func (e *endpoint) reportingURL(paths APIPaths, dst addr.IA, fp oracle.PathFingerprint) string {
+	return e.url(strings.NewReplacer(
+		"{isd}", strconv.FormatUint(uint64(dst.I), 10),
+		"{as}", strconv.FormatUint(uint64(dst.A), 10),
+		"{fp}", url.PathEscape(string(fp)),
+	).Replace(paths.Reports))
+}
+
+// supportedQuery removes the services the path oracle does not offer from query. It fails with
+// ErrServiceUnsupported if none of the queried services is offered.
+func (c *OracleClient) supportedQuery(ep *endpoint, caps *Capabilities, query server.ScoringQuery) (server.ScoringQuery, error) {
+	if len(caps.Services) == 0 {
+		return query, nil
+	}
+	supported := server.ScoringQuery{Queries: make(map[string][]services.ServiceName, len(query.Queries))}
+	var unsupported []services.ServiceName
+	for dst, svcs := range query.Queries {
+		for _, s := range svcs {
+			if caps.SupportsService(s) {
+				supported.Queries[dst] = append(supported.Queries[dst], s)
+			} else {
+				unsupported = append(unsupported, s)
+			}
+		}
+	}
+	if len(unsupported) == 0 {
+		return query, nil
+	}
+	if len(supported.Queries) == 0 {
+		return supported, fmt.Errorf("%w: %s offers %v, not %v", ErrServiceUnsupported, ep.baseURL, caps.Services,
+			unsupported)
+	}
+	c.logger.Debugw("not querying services the path oracle does not offer", "endpoint", ep.baseURL,
+		"services", unsupported)
+	return supported, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
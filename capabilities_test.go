@@ -0,0 +1,295 @@
+package oclient
+
+import (
+	"context"
+	"encoding/json"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"oclient/cbor"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// capabilitiesServer serves caps at the capabilities endpoint, or 404 if caps is nil, and records the paths
+// of all requests.
+func capabilitiesServer(caps interface{}) (*httptest.Server, func() []string) {
+	var mutex sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+		switch {
+		case r.URL.Path == capabilitiesPath && caps == nil:
+			http.NotFound(w, r)
+		case r.URL.Path == capabilitiesPath:
+			json.NewEncoder(w).Encode(caps)
+		case r.URL.Path == "/v2/scorings":
+			var q server.ScoringQuery
+			json.NewDecoder(r.Body).Decode(&q)
+			res := server.ScoringResponse{}
+			for dst, svcs := range q.Queries {
+				ia, _ := addr.IAFromString(dst)
+				scores := map[string]float64{}
+				for _, s := range svcs {
+					scores[string(s)] = 42
+				}
+				res[ia] = []server.FingerprintScores{{Fingerprint: "fp", Scores: scores}}
+			}
+			json.NewEncoder(w).Encode(res)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	return srv, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), paths...)
+	}
+}
+
+func TestCapabilitiesSelectLayoutAndServices(t *testing.T) {
+	srv, paths := capabilitiesServer(Capabilities{
+		Versions: []int{1, 2},
+		Paths:    APIPaths{Scorings: "/v2/scorings", Reports: "/v2/paths/{isd}-{as}/{fp}"},
+		Services: []services.ServiceName{"throughput"},
+	})
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	query := server.ScoringQuery{Queries: map[string][]services.ServiceName{
+		"1-ff00:0:110": {"throughput", "latency"},
+	}}
+	res, err := c.FetchScoresContext(context.Background(), query)
+	require.NoError(t, err)
+	dst := addr.IA{I: 1, A: 0xff0000000110}
+	assert.Equal(t, map[string]float64{"throughput": 42}, res[dst][0].Scores)
+
+	_, err = c.FetchScoresContext(context.Background(), server.ScoringQuery{Queries: map[string][]services.ServiceName{
+		"1-ff00:0:110": {"latency"},
+	}})
+	assert.ErrorIs(t, err, ErrServiceUnsupported)
+
+	report := oracle.Report{
+		Properties: oracle.MonitoredProperties{"throughput": 1.},
+		DstIA:      dst,
+		PathFp:     "1 2",
+	}
+	require.NoError(t, c.ReportStatsContext(context.Background(), report))
+	// the capabilities are queried once
+	assert.Equal(t, []string{capabilitiesPath, "/v2/scorings", "/v2/paths/1-280375465083152/1 2"}, paths())
+}
+
+func TestCapabilitiesOfLegacyOracles(t *testing.T) {
+	srv, paths := capabilitiesServer(nil)
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+	require.NoError(t, c.ReportStatsContext(context.Background(), report))
+	require.NoError(t, c.ReportStatsContext(context.Background(), report))
+	assert.Equal(t, []string{capabilitiesPath, "/reports/1/13/fp/", "/reports/1/13/fp/"}, paths())
+
+	caps, err := c.Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.True(t, caps.Legacy)
+	assert.Equal(t, defaultPaths, caps.Paths)
+}
+
+func TestCapabilitiesRejectIncompatibleOracles(t *testing.T) {
+	srv, paths := capabilitiesServer(Capabilities{Versions: []int{3}})
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+	for i := 0; i < 2; i++ {
+		err = c.ReportStatsContext(context.Background(), report)
+		assert.ErrorIs(t, err, ErrIncompatibleOracle)
+		var incompatible *IncompatibleError
+		require.ErrorAs(t, err, &incompatible)
+		assert.Equal(t, []int{3}, incompatible.Versions)
+	}
+	// the incompatibility is cached, nothing else is sent
+	assert.Equal(t, []string{capabilitiesPath}, paths())
+
+	srv, _ = capabilitiesServer(Capabilities{Versions: []int{1}, Paths: APIPaths{Reports: "/reports/"}})
+	defer srv.Close()
+	c, err = NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrIncompatibleOracle)
+}
+
+func TestCapabilitiesAdvertiseEncodings(t *testing.T) {
+	var mutex sync.Mutex
+	var contentTypes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == capabilitiesPath {
+			json.NewEncoder(w).Encode(Capabilities{Versions: []int{1}, Encodings: []string{cbor.ContentType}})
+			return
+		}
+		mutex.Lock()
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		mutex.Unlock()
+		// no Accept-Post header, the capabilities take precedence
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities(),
+		WithEncoding(EncodingConfig{CBOR: true}))
+	require.NoError(t, err)
+
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.ReportStatsContext(context.Background(), report))
+	}
+	assert.Equal(t, []string{cbor.ContentType, cbor.ContentType}, contentTypes)
+}
+
+func TestCapabilitiesQueryRetriedAfterFailure(t *testing.T) {
+	var mutex sync.Mutex
+	failing := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == capabilitiesPath {
+			json.NewEncoder(w).Encode(Capabilities{Versions: []int{1}})
+		}
+	}))
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	mutex.Lock()
+	failing = false
+	mutex.Unlock()
+	// the failure is cached until the backoff elapsed
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	ep := c.endpoints[0]
+	ep.capsMutex.Lock()
+	ep.capsRetryAt = time.Now()
+	ep.capsMutex.Unlock()
+	caps, err := c.Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.False(t, caps.Legacy)
+	assert.Equal(t, defaultPaths, caps.Paths)
+}
+
+func TestCapabilitiesQueryFailuresAreBackedOff(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities(),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 3, CoolDown: time.Hour}))
+	require.NoError(t, err)
+	ep := c.endpoints[0]
+
+	// the query is retried and recorded by the breaker
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	ep.capsMutex.Lock()
+	ep.capsRetryAt = time.Now()
+	ep.capsMutex.Unlock()
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.Equal(t, BreakerOpen, ep.breaker.currentState())
+	ep.capsMutex.Lock()
+	assert.Equal(t, 2, ep.capsFailures)
+	assert.WithinDuration(t, time.Now().Add(2*capabilitiesBackoff), ep.capsRetryAt, capabilitiesBackoff)
+	ep.capsRetryAt = time.Now()
+	ep.capsMutex.Unlock()
+
+	// the open breaker rejects the query without contacting the path oracle
+	_, err = c.Capabilities(context.Background())
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestScoresFailOverToOracleOfferingService(t *testing.T) {
+	latency, _ := capabilitiesServer(Capabilities{Versions: []int{1}, Services: []services.ServiceName{"latency"}})
+	defer latency.Close()
+	throughput, _ := capabilitiesServer(Capabilities{
+		Versions: []int{1},
+		Paths:    APIPaths{Scorings: "/v2/scorings"},
+		Services: []services.ServiceName{"throughput"},
+	})
+	defer throughput.Close()
+	c, err := NewOracleClient(WithEndpoints(Endpoint{URL: latency.URL}, Endpoint{URL: throughput.URL, Priority: 1}),
+		WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	res, err := c.FetchScoresContext(context.Background(), server.ScoringQuery{
+		Queries: map[string][]services.ServiceName{"1-ff00:0:110": {"throughput"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"throughput": 42}, res[addr.IA{I: 1, A: 0xff0000000110}][0].Scores)
+}
+
+func TestCapabilitiesQueryIsShared(t *testing.T) {
+	release := make(chan struct{})
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		json.NewEncoder(w).Encode(Capabilities{Versions: []int{1}})
+	}))
+	defer srv.Close()
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithCapabilities())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.Capabilities(ctx)
+		first <- err
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&requests) == 1 }, time.Second, time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			caps, err := c.Capabilities(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, []int{1}, caps.Versions)
+		}()
+	}
+
+	// the cache is not locked while the capabilities are queried
+	assert.Equal(t, defaultPaths, c.endpoints[0].cachedPaths())
+	assert.False(t, c.endpoints[0].advertisedEncodings())
+	// the caller which started the query gives up, the others get its result
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
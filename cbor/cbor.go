@@ -0,0 +1,83 @@
+// Package cbor encodes the types of the path oracle API as CBOR (RFC 8949) using github.com/fxamacker/cbor.
+// Struct fields are named by their json tags. The IAs keying scoring responses are encoded as text, like in JSON.
+package cbor
+
+import (
+	"fmt"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/scionproto/scion/go/lib/addr"
+	"reflect"
+)
+
+// ContentType is the media type of CBOR encoded bodies.
+const ContentType = "application/cbor"
+
+// maxDepth limits the nesting of decoded arrays and maps.
+const maxDepth = 64
+
+var (
+	// encMode sorts map keys and uses the shortest lossless float encoding, so equal values have equal encodings.
+	encMode cbor.EncMode
+	// decMode decodes maps into interface{} values as map[string]interface{}, as encoding/json does.
+	decMode cbor.DecMode
+)
+
+func init() {
+	var err error
+	if encMode, err = cbor.CoreDetEncOptions().EncMode(); err != nil {
+		panic(err)
+	}
+	decMode, err = cbor.DecOptions{
+		MaxNestedLevels: maxDepth,
+		DefaultMapType:  reflect.TypeOf(map[string]interface{}(nil)),
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// scoringResponse is the representation of a server.ScoringResponse, keyed by the text representation of the IAs.
+type scoringResponse map[string][]server.FingerprintScores
+
+// Marshal returns the CBOR encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	switch res := v.(type) {
+	case server.ScoringResponse:
+		v = toScoringResponse(res)
+	case *server.ScoringResponse:
+		if res != nil {
+			v = toScoringResponse(*res)
+		}
+	}
+	return encMode.Marshal(v)
+}
+
+// Unmarshal decodes the CBOR encoded data into v.
+func Unmarshal(data []byte, v interface{}) error {
+	res, ok := v.(*server.ScoringResponse)
+	if !ok {
+		return decMode.Unmarshal(data, v)
+	}
+	var encoded scoringResponse
+	if err := decMode.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	*res = make(server.ScoringResponse, len(encoded))
+	for rawDst, scores := range encoded {
+		dst, err := addr.IAFromString(rawDst)
+		if err != nil {
+			return fmt.Errorf("invalid destination %q: %w", rawDst, err)
+		}
+		(*res)[dst] = scores
+	}
+	return nil
+}
+
+func toScoringResponse(res server.ScoringResponse) scoringResponse {
+	encoded := make(scoringResponse, len(res))
+	for dst, scores := range res {
+		encoded[dst.String()] = scores
+	}
+	return encoded
+}
@@ -0,0 +1,145 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMarshalIsDeterministic(t *testing.T) {
+	for _, tc := range []struct {
+		v   interface{}
+		hex string
+	}{
+		{1.5, "f93e00"},
+		{0.1, "fb3fb999999999999a"},
+		{map[string]int{"bb": 2, "a": 1}, "a261610162626202"},
+	} {
+		data, err := Marshal(tc.v)
+		require.NoError(t, err)
+		assert.Equal(t, tc.hex, hex.EncodeToString(data), "%v", tc.v)
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	for _, h := range []string{"", "19", "62ff", "9b00000000ffffffff", "0000", "ff"} {
+		data, _ := hex.DecodeString(h)
+		var v interface{}
+		assert.Error(t, Unmarshal(data, &v), h)
+	}
+}
+
+func TestRoundTripOracleTypes(t *testing.T) {
+	dst := addr.IA{I: 1, A: 0xff0000000110}
+	res := server.ScoringResponse{dst: {{Fingerprint: "fp", Scores: map[string]float64{"throughput": 12.25}}}}
+	data, err := Marshal(res)
+	require.NoError(t, err)
+	var decoded server.ScoringResponse
+	require.NoError(t, Unmarshal(data, &decoded))
+	assert.Equal(t, res, decoded)
+
+	// destinations are keyed by their text representation, like in JSON
+	var generic map[string]interface{}
+	require.NoError(t, Unmarshal(data, &generic))
+	assert.Contains(t, generic, "1-ff00:0:110")
+
+	report := oracle.Report{
+		Metadata:   oracle.Metadata{Application: "quic", Properties: oracle.MetadataProperties{"k": "v"}},
+		Properties: oracle.MonitoredProperties{"throughput": 3.5},
+	}
+	data, err = Marshal(report)
+	require.NoError(t, err)
+	var decodedReport oracle.Report
+	require.NoError(t, Unmarshal(data, &decodedReport))
+	assert.Equal(t, report, decodedReport)
+}
+
+func benchmarkReport() oracle.Report {
+	return oracle.Report{
+		Metadata: oracle.Metadata{
+			Application: "quic_sender",
+			Duration:    10,
+			Properties: oracle.MetadataProperties{
+				"protocols":             []interface{}{"SCION", "UDP", "QUIC"},
+				"taps-capacity-profile": "capacity-seeking",
+			},
+		},
+		Properties: oracle.MonitoredProperties{"throughput": 12345678.9},
+	}
+}
+
+func benchmarkScoringResponse() server.ScoringResponse {
+	res := server.ScoringResponse{}
+	for as := 0; as < 4; as++ {
+		dst := addr.IA{I: 1, A: addr.AS(0xff0000000110 + as)}
+		for path := 0; path < 8; path++ {
+			res[dst] = append(res[dst], server.FingerprintScores{
+				Fingerprint: oracle.PathFingerprint(fmt.Sprintf("%064x", as*8+path)),
+				Scores:      map[string]float64{"throughput": float64(path) * 1.5e6, "latency": 0.25},
+			})
+		}
+	}
+	return res
+}
+
+// BenchmarkMarshal compares the encoding of reports and scoring responses as JSON and CBOR. The size of the
+// encodings is reported as B/msg.
+func BenchmarkMarshal(b *testing.B) {
+	for _, v := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"report", benchmarkReport()},
+		{"scorings", benchmarkScoringResponse()},
+	} {
+		for _, codec := range []struct {
+			name    string
+			marshal func(interface{}) ([]byte, error)
+		}{
+			{"json", json.Marshal},
+			{"cbor", Marshal},
+		} {
+			b.Run(v.name+"/"+codec.name, func(b *testing.B) {
+				var data []byte
+				var err error
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if data, err = codec.marshal(v.value); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(data)), "B/msg")
+			})
+		}
+	}
+}
+
+// BenchmarkUnmarshal compares the decoding of scoring responses from JSON and CBOR.
+func BenchmarkUnmarshal(b *testing.B) {
+	for _, codec := range []struct {
+		name      string
+		marshal   func(interface{}) ([]byte, error)
+		unmarshal func([]byte, interface{}) error
+	}{
+		{"json", json.Marshal, json.Unmarshal},
+		{"cbor", Marshal, Unmarshal},
+	} {
+		b.Run("scorings/"+codec.name, func(b *testing.B) {
+			data, err := codec.marshal(benchmarkScoringResponse())
+			require.NoError(b, err)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var res server.ScoringResponse
+				if err := codec.unmarshal(data, &res); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
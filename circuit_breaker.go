@@ -0,0 +1,142 @@
+package oclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the path oracle while the circuit breaker is open.
+// It matches ErrOracleUnavailable.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", ErrOracleUnavailable)
+
+// BreakerState is the state of the circuit breaker guarding requests to the path oracle.
+type BreakerState int
+
+const (
+	// BreakerClosed lets all requests pass, the oracle is considered healthy.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all requests until the cool-down elapsed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request pass to probe whether the oracle recovered.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed attempts after which the breaker opens.
+	FailureThreshold int
+	// CoolDown is the time the breaker stays open before a trial request is let through.
+	CoolDown time.Duration
+}
+
+// DefaultCircuitBreakerConfig opens the breaker after 5 consecutive failures for 30 seconds.
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold: 5,
+	CoolDown:         30 * time.Second,
+}
+
+type circuitBreaker struct {
+	mutex    sync.Mutex
+	config   CircuitBreakerConfig
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	// trial is true while the single request of the half-open state is in flight
+	trial bool
+	now   func() time.Time
+}
+
+func newCircuitBreaker(config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{config: config, now: time.Now}
+}
+
+// allow reports whether a request may be sent. A true result must be followed by either success or failure.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.config.CoolDown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == BreakerHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release gives up a permit obtained by allow without judging the health of the oracle,
+// e.g. because the caller cancelled the request.
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) currentState() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == BreakerOpen && b.now().Sub(b.openedAt) >= b.config.CoolDown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,35 @@
+package oclient
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, CoolDown: time.Minute})
+	b.now = func() time.Time { return now }
+
+	assert.True(t, b.allow())
+	b.failure()
+	assert.Equal(t, BreakerClosed, b.currentState())
+	assert.True(t, b.allow())
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.currentState())
+	assert.False(t, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, BreakerHalfOpen, b.currentState())
+	assert.True(t, b.allow())
+	// only a single trial request passes while half-open
+	assert.False(t, b.allow())
+	b.failure()
+	assert.Equal(t, BreakerOpen, b.currentState())
+
+	now = now.Add(time.Minute)
+	assert.True(t, b.allow())
+	b.success()
+	assert.Equal(t, BreakerClosed, b.currentState())
+	assert.True(t, b.allow())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"go.uber.org/zap"
+	"net/http"
+	"oclient/oracletest"
+	"oclient/signing"
+	"strings"
+)
+
+const dumpPath = "/dump/"
+
+func main() {
+	var (
+		listenAddr string
+		scoresFile string
+		service    string
+		alpha      float64
+		trustKeys  string
+	)
+	flag.StringVar(&listenAddr, "listen", "127.0.0.1:8080", "TCP address to serve the oracle API on")
+	flag.StringVar(&scoresFile, "scores", "", "JSON or YAML file with initial scores (optional)")
+	flag.StringVar(&service, "service", "throughput", "service and report property aggregated into scores")
+	flag.Float64Var(&alpha, "alpha", 0.3, "weight of a new report in the EWMA of a path's score, in (0, 1]")
+	flag.StringVar(&trustKeys, "trust", "", "comma separated PEM files with Ed25519 public keys - if set, only reports signed by one of them are accepted")
+	flag.Parse()
+
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+	slogger := logger.Sugar()
+
+	if alpha <= 0 || alpha > 1 {
+		slogger.Fatalw("alpha must be in (0, 1]", "alpha", alpha)
+	}
+
+	o := oracletest.New()
+	if scoresFile != "" {
+		n, err := loadScores(o, scoresFile)
+		if err != nil {
+			slogger.Fatalw("error loading scores", "error", err, "file", scoresFile)
+		}
+		slogger.Infow("loaded initial scores", "file", scoresFile, "scores", n)
+	}
+	if trustKeys != "" {
+		verifier := signing.NewVerifier()
+		for _, file := range strings.Split(trustKeys, ",") {
+			pub, err := signing.LoadPublicKey(file)
+			if err != nil {
+				slogger.Fatalw("error loading trusted key", "error", err, "file", file)
+			}
+			verifier.Trust(signing.KeyID(pub), pub)
+			slogger.Infow("trusting reports signed by key", "file", file, "key_id", signing.KeyID(pub))
+		}
+		o.Validate = func(report oracle.Report) error {
+			err := verifier.Verify(report)
+			if err != nil {
+				slogger.Infow("rejecting report", "error", err, "dst", report.DstIA, "fingerprint", report.PathFp)
+			}
+			return err
+		}
+	}
+	agg := newAggregator(o, service, alpha, slogger)
+	o.OnReport = agg.onReport
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(dumpPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(agg.dump())
+	})
+	mux.Handle("/", o)
+
+	slogger.Infow("serving mock oracle", "listen", listenAddr, "dump", "http://"+listenAddr+dumpPath)
+	if err := http.ListenAndServe(listenAddr, mux); err != nil {
+		slogger.Fatalw("error serving mock oracle", "error", err)
+	}
+}
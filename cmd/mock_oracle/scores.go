@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"oclient/oracletest"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// scoresFile maps destination IA -> path fingerprint -> service -> score, e.g.
+//
+//	1-ff00:0:110:
+//	  f8d2...:
+//	    throughput: 1250000
+type scoresFile map[string]map[oracle.PathFingerprint]map[string]float64
+
+// loadScores programs the scores in the JSON or YAML file at path into o and returns their number.
+func loadScores(o *oracletest.Oracle, path string) (int, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var f scoresFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, &f)
+	default:
+		err = json.Unmarshal(raw, &f)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	n := 0
+	for rawDst, paths := range f {
+		dst, err := addr.IAFromString(rawDst)
+		if err != nil {
+			return 0, fmt.Errorf("invalid destination %q: %w", rawDst, err)
+		}
+		for fp, scores := range paths {
+			for service, score := range scores {
+				o.SetScore(dst, fp, service, score)
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+type pathKey struct {
+	dst addr.IA
+	fp  oracle.PathFingerprint
+}
+
+// aggregator turns reports into scores using an exponentially weighted moving average per path.
+type aggregator struct {
+	oracle  *oracletest.Oracle
+	service string
+	alpha   float64
+	logger  *zap.SugaredLogger
+
+	mutex   sync.Mutex
+	reports map[pathKey]int
+}
+
+func newAggregator(o *oracletest.Oracle, service string, alpha float64, logger *zap.SugaredLogger) *aggregator {
+	return &aggregator{
+		oracle:  o,
+		service: service,
+		alpha:   alpha,
+		logger:  logger,
+		reports: make(map[pathKey]int),
+	}
+}
+
+func (a *aggregator) onReport(report oracle.Report) {
+	value, ok := report.Properties[a.service].(float64)
+	if !ok {
+		a.logger.Infow("ignoring report without property", "property", a.service, "fingerprint", report.PathFp)
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	score := value
+	if old, ok := a.oracle.Score(report.DstIA, report.PathFp, a.service); ok {
+		score = a.alpha*value + (1-a.alpha)*old
+	}
+	a.oracle.SetScore(report.DstIA, report.PathFp, a.service, score)
+	a.reports[pathKey{report.DstIA, report.PathFp}]++
+	a.logger.Infow("aggregated report", "dst", report.DstIA, "fingerprint", report.PathFp,
+		a.service, value, "score", score)
+}
+
+type pathDump struct {
+	Scores  map[string]float64 `json:"scores"`
+	Reports int                `json:"reports"`
+}
+
+// dump returns the scores and number of aggregated reports of all known paths.
+func (a *aggregator) dump() map[addr.IA]map[oracle.PathFingerprint]pathDump {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	res := make(map[addr.IA]map[oracle.PathFingerprint]pathDump)
+	for dst, paths := range a.oracle.AllScores() {
+		res[dst] = make(map[oracle.PathFingerprint]pathDump, len(paths))
+		for fp, scores := range paths {
+			res[dst][fp] = pathDump{Scores: scores, Reports: a.reports[pathKey{dst, fp}]}
+		}
+	}
+	return res
+}
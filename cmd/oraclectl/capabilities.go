@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"oclient"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func runCapabilities(ctx context.Context, c *oclient.OracleClient, args []string) error {
+	fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the capabilities as JSON instead of a table")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(caps)
+	}
+	services := make([]string, len(caps.Services))
+	for i, s := range caps.Services {
+		services[i] = string(s)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "endpoint\t%s\n", c.BaseURL())
+	fmt.Fprintf(w, "versions\t%v (client supports %v)\n", caps.Versions, oclient.APIVersions)
+	fmt.Fprintf(w, "legacy\t%t\n", caps.Legacy)
+	fmt.Fprintf(w, "scorings\t%s\n", caps.Paths.Scorings)
+	fmt.Fprintf(w, "subscribe\t%s\n", caps.Paths.Subscribe)
+	fmt.Fprintf(w, "reports\t%s\n", caps.Paths.Reports)
+	fmt.Fprintf(w, "batch\t%s\n", caps.Paths.Batch)
+	fmt.Fprintf(w, "services path\t%s\n", caps.Paths.Services)
+	fmt.Fprintf(w, "encodings\t%s\n", orUnknown(strings.Join(caps.Encodings, ", ")))
+	fmt.Fprintf(w, "content encodings\t%s\n", orUnknown(strings.Join(caps.ContentEncodings, ", ")))
+	fmt.Fprintf(w, "services\t%s\n", orUnknown(strings.Join(services, ", ")))
+	return w.Flush()
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,147 @@
+// oraclectl inspects and seeds path oracles from the shell.
+//
+// Usage:
+//
+//	oraclectl [flags] scores <IA> [-service throughput,latency] [-json]
+//	oraclectl [flags] report -dst <IA> -fp <fingerprint> -property throughput=1e6 | -file reports.json
+//	oraclectl [flags] replay [-dst <IA>] [-shift 720h | -startAt now] [-rate 10] [-dryRun] <interval csv> ...
+//	oraclectl [flags] ping
+//	oraclectl [flags] capabilities [-json]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/zap"
+	"oclient"
+	"os"
+	"strings"
+	"time"
+)
+
+// command is a subcommand, parsing its own arguments.
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *oclient.OracleClient, args []string) error
+}
+
+var commands = map[string]command{
+	"scores":       {"<IA> [-service throughput,latency] [-json] [-paths=false]", runScores},
+	"report":       {"-dst <IA> -fp <fingerprint> -property name=value ... | -file reports.json", runReport},
+	"replay":       {"[-dst <IA>] [-src <IA>] [-shift <duration> | -startAt <time>] [-rate <reports/s>] [-dryRun] <interval csv> ...", runReplay},
+	"ping":         {"", runPing},
+	"capabilities": {"[-json]", runCapabilities},
+}
+
+// logger logs the interaction with the path oracle if -v is given.
+var logger = zap.NewNop().Sugar()
+
+func main() {
+	var (
+		oracleURL     string
+		oracleNetwork string
+		oracleToken   string
+		timeout       time.Duration
+		verbose       bool
+		capabilities  bool
+	)
+	flag.StringVar(&oracleURL, "oracle", defaultOracleURL(), "base URL of the path oracle, defaults to http://$PATH_ORACLE")
+	flag.StringVar(&oracleNetwork, "oracleNetwork", oclient.NetworkAuto.String(), "network the path oracle is reached by: scion, ip or auto (scion for SCION addresses, ip otherwise)")
+	flag.StringVar(&oracleToken, "oracleToken", os.Getenv("PATH_ORACLE_TOKEN"), "bearer token authenticating requests to the path oracle, defaults to $PATH_ORACLE_TOKEN")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "maximum time the command runs")
+	flag.BoolVar(&verbose, "v", false, "log the interaction with the path oracle")
+	flag.BoolVar(&capabilities, "capabilities", true, "query the capabilities of the path oracle before talking to it, failing if it is incompatible")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	zapLogger := zap.NewNop()
+	if verbose {
+		zapLogger, _ = zap.NewDevelopment()
+	}
+	defer zapLogger.Sync()
+	logger = zapLogger.Sugar()
+
+	network, err := oclient.ParseNetwork(oracleNetwork)
+	if err != nil {
+		fatal(err)
+	}
+	opts := []oclient.Option{
+		oclient.WithBaseURL(oracleURL),
+		oclient.WithNetwork(network),
+		oclient.WithUserAgent("oraclectl"),
+		oclient.WithLogger(logger),
+	}
+	if capabilities {
+		opts = append(opts, oclient.WithCapabilities())
+	}
+	if oracleToken != "" {
+		opts = append(opts, oclient.WithBearerToken(oracleToken))
+	}
+	c, err := oclient.NewOracleClient(opts...)
+	if err != nil {
+		fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := cmd.run(ctx, c, flag.Args()[1:]); err != nil {
+		fatal(fmt.Errorf("%s: %w", flag.Arg(0), err))
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [arguments]\n\nCommands:\n", os.Args[0])
+	for _, name := range []string{"scores", "report", "replay", "ping", "capabilities"} {
+		fmt.Fprintf(out, "  %s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "oraclectl:", err)
+	os.Exit(1)
+}
+
+// parseArgs parses the flags of a command, which may follow its positional arguments, and returns the latter.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitList splits a comma separated list, ignoring empty elements.
+func splitList(s string) []string {
+	var elems []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+func defaultOracleURL() string {
+	if location := os.Getenv("PATH_ORACLE"); location != "" {
+		return "http://" + location
+	}
+	return ""
+}
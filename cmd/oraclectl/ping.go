@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"oclient"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func runPing(ctx context.Context, c *oclient.OracleClient, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tSTATUS\tRTT")
+	healthy := 0
+	for _, res := range c.Ping(ctx) {
+		status := "ok"
+		if res.Err != nil {
+			status = res.Err.Error()
+		} else {
+			healthy++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", res.URL, status, res.RTT.Round(time.Millisecond))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if healthy == 0 {
+		return errors.New("no path oracle is healthy")
+	}
+	return nil
+}
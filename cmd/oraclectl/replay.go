@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"oclient"
+	"oclient/reporting"
+	"os"
+	"time"
+)
+
+func runReplay(ctx context.Context, c *oclient.OracleClient, args []string) error {
+	var (
+		config  = reporting.DefaultReplayConfig
+		startAt string
+	)
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Var(&config.DstIA, "dst", "destination IA the intervals were measured to, defaults to the recorded one")
+	fs.Var(&config.SrcIA, "src", "source IA the intervals were measured from, defaults to the recorded one")
+	fs.DurationVar(&config.Shift, "shift", 0, "time added to the begin and end of all intervals, e.g. 720h")
+	fs.StringVar(&startAt, "startAt", "", "RFC 3339 time the earliest interval is shifted to, 'now' for the current time")
+	fs.Float64Var(&config.Rate, "rate", config.Rate, "maximum number of reports submitted per second, 0 for no limit")
+	fs.BoolVar(&config.DryRun, "dryRun", false, "print the reconstructed reports as JSON instead of submitting them")
+	fs.StringVar(&config.Application, "application", config.Application, "application the reports are attributed to")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("expected at least one interval csv")
+	}
+	switch startAt {
+	case "":
+	case "now":
+		config.StartAt = time.Now()
+	default:
+		if config.StartAt, err = time.Parse(time.RFC3339, startAt); err != nil {
+			return fmt.Errorf("parsing -startAt: %w", err)
+		}
+	}
+
+	var intervals []reporting.Interval
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		read, err := reporting.ReadIntervals(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		intervals = append(intervals, read...)
+	}
+
+	replayer := reporting.NewReplayer(c, config, logger)
+	result, err := replayer.Replay(ctx, intervals)
+	if config.DryRun && err == nil {
+		// the output can be submitted later on with report -file
+		reports := make([]fileReport, 0, len(result.Reports))
+		for _, r := range result.Reports {
+			reports = append(reports, fileReport{SrcIA: r.SrcIA, DstIA: r.DstIA, PathFp: r.PathFp, Report: r})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+	fmt.Printf("replayed %d intervals: %d submitted, %d rejected, %d skipped without path\n",
+		len(intervals), result.Submitted, result.Rejected, result.Skipped)
+	return err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io"
+	"oclient"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileReport is a report in a JSON file, carrying its path parameters in the body like reports in a batch.
+type fileReport struct {
+	SrcIA  addr.IA                `json:"src_ia"`
+	DstIA  addr.IA                `json:"dst_ia"`
+	PathFp oracle.PathFingerprint `json:"path_fp"`
+	oracle.Report
+}
+
+// properties collects name=value flags, numeric values are stored as float64.
+type properties map[string]interface{}
+
+func (p properties) String() string {
+	return fmt.Sprint(map[string]interface{}(p))
+}
+
+func (p properties) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("expected name=value, got %q", s)
+	}
+	name, value := s[:i], s[i+1:]
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		p[name] = f
+	} else {
+		p[name] = value
+	}
+	return nil
+}
+
+func runReport(ctx context.Context, c *oclient.OracleClient, args []string) error {
+	var (
+		report   = oracle.Report{Properties: oracle.MonitoredProperties{}}
+		meta     = properties{}
+		fp       string
+		file     string
+		duration time.Duration
+	)
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Var(&report.DstIA, "dst", "destination IA of the path")
+	fs.Var(&report.SrcIA, "src", "source IA of the path (optional)")
+	fs.StringVar(&fp, "fp", "", "fingerprint of the path")
+	fs.Var(properties(report.Properties), "property", "monitored property name=value, repeatable, e.g. throughput=1.5e6")
+	fs.Var(meta, "meta", "metadata property name=value, repeatable")
+	fs.StringVar(&report.Metadata.Application, "application", "oraclectl", "application the stats were measured by")
+	fs.DurationVar(&duration, "duration", time.Minute, "duration of the connection the stats were measured on")
+	fs.StringVar(&file, "file", "", "JSON file with a report or an array of reports carrying dst_ia, path_fp and optionally src_ia, '-' for stdin")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	var reports []oracle.Report
+	if file != "" {
+		if reports, err = readReports(file); err != nil {
+			return err
+		}
+	} else {
+		if report.DstIA.IsZero() || fp == "" {
+			return errors.New("-dst and -fp are required without -file")
+		}
+		report.PathFp = oracle.PathFingerprint(fp)
+		report.Metadata.Duration = duration.Seconds()
+		report.Metadata.Properties = oracle.MetadataProperties(meta)
+		reports = []oracle.Report{report}
+	}
+
+	for _, r := range reports {
+		if err := c.ReportStatsContext(ctx, r); err != nil {
+			return fmt.Errorf("reporting path %q to %s: %w", r.PathFp, r.DstIA, err)
+		}
+	}
+	fmt.Printf("submitted %d reports\n", len(reports))
+	return nil
+}
+
+// readReports reads a report or an array of reports from a JSON file.
+func readReports(file string) ([]oracle.Report, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fileReports []fileReport
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &fileReports)
+	} else {
+		fileReports = make([]fileReport, 1)
+		err = json.Unmarshal(data, &fileReports[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+
+	reports := make([]oracle.Report, len(fileReports))
+	for i, fr := range fileReports {
+		if fr.DstIA.IsZero() || fr.PathFp == "" {
+			return nil, fmt.Errorf("report %d in %s lacks dst_ia or path_fp", i, file)
+		}
+		reports[i] = fr.Report
+		reports[i].SrcIA, reports[i].DstIA, reports[i].PathFp = fr.SrcIA, fr.DstIA, fr.PathFp
+	}
+	return reports, nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/scionproto/scion/go/lib/daemon"
+	"github.com/scionproto/scion/go/lib/sock/reliable"
+	"inet.af/netaddr"
+	"oclient"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+)
+
+// scoredPath is a path known to the path oracle, the local SCION daemon or both.
+type scoredPath struct {
+	Fingerprint oracle.PathFingerprint           `json:"fingerprint"`
+	Hops        *int                             `json:"hops,omitempty"`
+	Local       bool                             `json:"local"`
+	Scores      map[services.ServiceName]float64 `json:"scores"`
+}
+
+func runScores(ctx context.Context, c *oclient.OracleClient, args []string) error {
+	fs := flag.NewFlagSet("scores", flag.ExitOnError)
+	service := fs.String("service", "throughput", "comma separated services to query scores of")
+	asJSON := fs.Bool("json", false, "print the paths as JSON instead of a table")
+	withPaths := fs.Bool("paths", true, "join the scores with the paths available locally, requires a SCION daemon")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return errors.New("expected a single destination IA")
+	}
+	dst, err := addr.IAFromString(args[0])
+	if err != nil {
+		return err
+	}
+	var svcs []services.ServiceName
+	for _, s := range splitList(*service) {
+		svcs = append(svcs, services.ServiceName(s))
+	}
+	if len(svcs) == 0 {
+		return errors.New("no service given")
+	}
+
+	scoreSet, err := c.Scores(ctx, map[addr.IA][]services.ServiceName{dst: svcs})
+	if err != nil {
+		return err
+	}
+	var local []*pan.Path
+	if *withPaths {
+		if local, err = localPaths(ctx, pan.IA(dst)); err != nil {
+			fmt.Fprintln(os.Stderr, "oraclectl: showing scores only, error looking up local paths:", err)
+		}
+	}
+	paths := joinPaths(scoreSet, dst, local, svcs[0])
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(paths)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "FINGERPRINT\tHOPS\tLOCAL")
+	for _, svc := range svcs {
+		fmt.Fprintf(w, "\t%s", svc)
+	}
+	fmt.Fprintln(w)
+	for _, p := range paths {
+		hops := "-"
+		if p.Hops != nil {
+			hops = strconv.Itoa(*p.Hops)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t", p.Fingerprint, hops, p.Local)
+		for _, svc := range svcs {
+			if score, ok := p.Scores[svc]; ok {
+				fmt.Fprintf(w, "\t%g", score)
+			} else {
+				fmt.Fprint(w, "\t-")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// joinPaths merges the scored paths to dst with the local paths, sorted by the score of service (DESC) and
+// hops (ASC) like the ThroughputPathSelector ranks them.
+func joinPaths(scoreSet oclient.ScoreSet, dst addr.IA, local []*pan.Path, service services.ServiceName) []scoredPath {
+	byFp := make(map[oracle.PathFingerprint]*scoredPath)
+	for _, fp := range scoredFingerprints(scoreSet, dst) {
+		byFp[fp] = &scoredPath{Fingerprint: fp, Scores: scoreSet.Path(dst, fp)}
+	}
+	for _, p := range local {
+		fp := oracle.PathFingerprint(p.Fingerprint)
+		sp, ok := byFp[fp]
+		if !ok {
+			sp = &scoredPath{Fingerprint: fp, Scores: map[services.ServiceName]float64{}}
+			byFp[fp] = sp
+		}
+		sp.Local = true
+		if p.Metadata != nil {
+			hops := len(p.Metadata.Interfaces)
+			sp.Hops = &hops
+		}
+	}
+
+	paths := make([]scoredPath, 0, len(byFp))
+	for _, sp := range byFp {
+		paths = append(paths, *sp)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		sI, sJ := paths[i].Scores[service], paths[j].Scores[service]
+		if sI != sJ {
+			return sI > sJ
+		}
+		hI, hJ := hopsOrMax(paths[i].Hops), hopsOrMax(paths[j].Hops)
+		if hI != hJ {
+			return hI < hJ
+		}
+		return paths[i].Fingerprint < paths[j].Fingerprint
+	})
+	return paths
+}
+
+// scoredFingerprints returns the fingerprints of all paths to dst with a score of any service.
+func scoredFingerprints(scoreSet oclient.ScoreSet, dst addr.IA) []oracle.PathFingerprint {
+	var fps []oracle.PathFingerprint
+	for fp := range scoreSet[dst] {
+		fps = append(fps, fp)
+	}
+	return fps
+}
+
+func hopsOrMax(hops *int) int {
+	if hops == nil {
+		return int(^uint(0) >> 1)
+	}
+	return *hops
+}
+
+// pathCollector is a selector keeping the paths it is initialized with.
+type pathCollector struct {
+	paths []*pan.Path
+}
+
+func (p *pathCollector) Path() *pan.Path {
+	if len(p.paths) == 0 {
+		return nil
+	}
+	return p.paths[0]
+}
+
+func (p *pathCollector) Initialize(local, remote pan.UDPAddr, paths []*pan.Path) {
+	p.paths = paths
+}
+
+func (p *pathCollector) Refresh(paths []*pan.Path) {
+	p.paths = paths
+}
+
+func (p *pathCollector) PathDown(pan.PathFingerprint, pan.PathInterface) {}
+
+func (p *pathCollector) Close() error {
+	return nil
+}
+
+// localPaths looks up the paths to dst from the SCION daemon. No packets are sent.
+func localPaths(ctx context.Context, dst pan.IA) ([]*pan.Path, error) {
+	if err := checkSCION(ctx); err != nil {
+		return nil, err
+	}
+	collector := &pathCollector{}
+	remote := pan.UDPAddr{IA: dst, IP: netaddr.IPv4(127, 0, 0, 1), Port: 9}
+	conn, err := pan.DialUDP(ctx, netaddr.IPPort{}, remote, nil, collector)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return collector.paths, nil
+}
+
+// checkSCION returns an error if the dispatcher or the daemon used by pan are not available, as pan exits the
+// process if it fails to connect to them.
+func checkSCION(ctx context.Context) error {
+	socket, ok := os.LookupEnv("SCION_DISPATCHER_SOCKET")
+	if !ok {
+		socket = reliable.DefaultDispPath
+	}
+	if fi, err := os.Stat(socket); err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("no SCION dispatcher socket at %s (override with SCION_DISPATCHER_SOCKET)", socket)
+	}
+	address, ok := os.LookupEnv("SCION_DAEMON_ADDRESS")
+	if !ok {
+		address = daemon.DefaultAPIAddress
+	}
+	conn, err := daemon.NewService(address).Connect(ctx)
+	if err == nil {
+		_, err = conn.LocalIA(ctx)
+		conn.Close(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("no SCION daemon at %s (override with SCION_DAEMON_ADDRESS): %w", address, err)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// txtURLPrefix marks TXT records carrying the base URL of a path oracle, e.g. "url=http://1-ff00:0:110,[10.0.0.1]:8080".
+const txtURLPrefix = "url="
+
+// ErrNoOracleDiscovered indicates that no path oracle was found by a Discoverer.
+var ErrNoOracleDiscovered = errors.New("no path oracle discovered")
+
+// DNSResolver looks up the DNS records used for discovery. It is implemented by net.Resolver.
+type DNSResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DiscoveryConfig configures where a Discoverer looks for path oracles.
+type DiscoveryConfig struct {
+	// Domain of the local AS. Path oracles are looked up in the TXT records of _<Service>.<Domain> and the
+	// SRV records of _<Service>._tcp.<Domain>. Empty to skip the DNS lookups.
+	Domain string
+	// Service is the name of the path oracle service in DNS records.
+	Service string
+	// Scheme of the base URLs built from SRV records.
+	Scheme string
+	// Host is resolved by the SCION resolver, i.e. the hosts files and RAINS, e.g. "path-oracle:8080".
+	// Empty to skip the lookup.
+	Host string
+	// TTL is the time discovered path oracles are reused by Discover and by clients, see WithDiscovery. Once
+	// expired, a failing discovery keeps returning the last discovered path oracles. 0 to look them up on every
+	// call of Discover, clients then never refresh their path oracles.
+	TTL time.Duration
+	// Timeout of a single discovery.
+	Timeout time.Duration
+	// Resolver used for DNS lookups, net.DefaultResolver if nil.
+	Resolver DNSResolver
+}
+
+// DefaultDiscoveryConfig looks up the "path-oracle" service, reusing the result for ten minutes.
+var DefaultDiscoveryConfig = DiscoveryConfig{
+	Service: "path-oracle",
+	Scheme:  "http",
+	TTL:     10 * time.Minute,
+	Timeout: 5 * time.Second,
+}
+
+// Discoverer locates the path oracles of the local AS from DNS and RAINS records and caches the result.
+// It is safe for concurrent use and can be shared by several clients.
+type Discoverer struct {
+	config      DiscoveryConfig
+	resolveHost func(address string) (pan.UDPAddr, error)
+	now         func() time.Time
+
+	mutex      sync.Mutex
+	discovered []Endpoint
+	expires    time.Time
+}
+
+// NewDiscoverer creates a Discoverer, unset fields of config are taken from DefaultDiscoveryConfig.
+func NewDiscoverer(config DiscoveryConfig) *Discoverer {
+	if config.Service == "" {
+		config.Service = DefaultDiscoveryConfig.Service
+	}
+	if config.Scheme == "" {
+		config.Scheme = DefaultDiscoveryConfig.Scheme
+	}
+	if config.Resolver == nil {
+		config.Resolver = net.DefaultResolver
+	}
+	return &Discoverer{
+		config:      config,
+		resolveHost: pan.ResolveUDPAddr,
+		now:         time.Now,
+	}
+}
+
+// Discover returns the path oracles of the local AS, ordered by priority: TXT records before SRV records
+// before the SCION resolver. ErrNoOracleDiscovered is returned if none was found and none was discovered before.
+func (d *Discoverer) Discover(ctx context.Context) ([]Endpoint, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.discovered != nil && d.now().Before(d.expires) {
+		return d.discovered, nil
+	}
+
+	if d.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.Timeout)
+		defer cancel()
+	}
+	discovered, err := d.lookup(ctx)
+	if err != nil {
+		if d.discovered != nil {
+			return d.discovered, nil
+		}
+		return nil, err
+	}
+	d.discovered = discovered
+	d.expires = d.now().Add(d.config.TTL)
+	return discovered, nil
+}
+
+func (d *Discoverer) lookup(ctx context.Context) ([]Endpoint, error) {
+	var (
+		urls []string
+		errs []string
+	)
+	if d.config.Domain != "" {
+		domain := strings.TrimSuffix(d.config.Domain, ".")
+		records, err := d.config.Resolver.LookupTXT(ctx, "_"+d.config.Service+"."+domain)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		for _, record := range records {
+			if strings.HasPrefix(record, txtURLPrefix) {
+				urls = append(urls, strings.TrimPrefix(record, txtURLPrefix))
+			}
+		}
+
+		_, srvs, err := d.config.Resolver.LookupSRV(ctx, d.config.Service, "tcp", domain)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		for _, srv := range srvs {
+			host := net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+			urls = append(urls, d.config.Scheme+"://"+host)
+		}
+	}
+	if d.config.Host != "" {
+		addr, err := d.resolveHost(d.config.Host)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			urls = append(urls, d.config.Scheme+"://"+addr.String())
+		}
+	}
+
+	if len(urls) == 0 {
+		if len(errs) == 0 {
+			return nil, ErrNoOracleDiscovered
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoOracleDiscovered, strings.Join(errs, "; "))
+	}
+	endpoints := make([]Endpoint, len(urls))
+	for i, u := range urls {
+		endpoints[i] = Endpoint{URL: u, Priority: i}
+	}
+	return endpoints, nil
+}
+
+// rediscovery repeats the discovery of a client once the TTL of its discoverer expired.
+type rediscovery struct {
+	discoverer *Discoverer
+	// configured are the path oracles given by WithBaseURL and WithEndpoints
+	configured  []Endpoint
+	newEndpoint func(Endpoint) (*endpoint, error)
+
+	mutex   sync.Mutex
+	due     time.Time
+	running bool
+}
+
+// rediscover starts repeating the discovery in the background if it is due.
+func (c *OracleClient) rediscover() {
+	r := c.rediscovery
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.running || r.discoverer.now().Before(r.due) {
+		return
+	}
+	r.running = true
+	go c.refreshEndpoints()
+}
+
+// refreshEndpoints discovers the path oracles again and replaces the endpoints if they changed. Endpoints which are
+// discovered again keep their health, circuit breaker and capabilities. A failing discovery keeps the endpoints.
+func (c *OracleClient) refreshEndpoints() {
+	r := c.rediscovery
+	discovered, err := r.discoverer.Discover(context.Background())
+	r.mutex.Lock()
+	r.running = false
+	r.due = r.discoverer.now().Add(r.discoverer.config.TTL)
+	r.mutex.Unlock()
+	if err != nil {
+		c.logger.Infow("path oracle rediscovery failed, keeping path oracles", "error", err)
+		return
+	}
+
+	c.endpointsMutex.Lock()
+	defer c.endpointsMutex.Unlock()
+	current := make(map[string]*endpoint, len(c.endpoints))
+	for _, ep := range c.endpoints {
+		current[ep.URL] = ep
+	}
+	configured := sortByPriority(prefer(discovered, r.configured))
+	eps := make([]*endpoint, 0, len(configured))
+	urls := make([]string, 0, len(configured))
+	changed := len(configured) != len(c.endpoints)
+	for _, e := range configured {
+		ep, ok := current[e.URL]
+		if !ok {
+			if ep, err = r.newEndpoint(e); err != nil {
+				c.logger.Warnw("ignoring discovered path oracle", "url", e.URL, "error", err)
+				changed = true
+				continue
+			}
+		}
+		changed = changed || c.endpoints[len(eps)] != ep
+		eps = append(eps, ep)
+		urls = append(urls, ep.baseURL.String())
+	}
+	if !changed || len(eps) == 0 {
+		return
+	}
+	c.logger.Infow("path oracles changed", "endpoints", urls)
+	c.endpoints = eps
+}
+
+// sortByPriority sorts endpoints by priority, keeping the order of endpoints with equal priority.
+func sortByPriority(endpoints []Endpoint) []Endpoint {
+	sort.SliceStable(endpoints, func(i, j int) bool { return endpoints[i].Priority < endpoints[j].Priority })
+	return endpoints
+}
+
+// prefer returns the preferred endpoints followed by the fallback endpoints, changing the priorities of the
+// preferred endpoints to rank before all fallback endpoints while keeping their order.
+func prefer(preferred, fallback []Endpoint) []Endpoint {
+	if len(preferred) == 0 {
+		return fallback
+	}
+	endpoints := make([]Endpoint, 0, len(preferred)+len(fallback))
+	for i, e := range preferred {
+		if len(fallback) > 0 {
+			e.Priority = minPriority(fallback) - len(preferred) + i
+		}
+		endpoints = append(endpoints, e)
+	}
+	return append(endpoints, fallback...)
+}
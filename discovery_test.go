@@ -0,0 +1,131 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	txt     map[string][]string
+	srv     map[string][]*net.SRV
+	lookups int
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.lookups++
+	cname := "_" + service + "._" + proto + "." + name
+	if srvs, ok := r.srv[cname]; ok {
+		return cname, srvs, nil
+	}
+	return "", nil, &net.DNSError{Err: "no such host", Name: cname, IsNotFound: true}
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	r.lookups++
+	if txt, ok := r.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestDiscoverOrdersRecords(t *testing.T) {
+	resolver := &fakeResolver{
+		txt: map[string][]string{"_path-oracle.as110.example": {"v=1", "url=http://1-ff00:0:110,[10.0.0.1]:8080"}},
+		srv: map[string][]*net.SRV{"_path-oracle._tcp.as110.example": {
+			{Target: "oracle1.as110.example.", Port: 8080},
+			{Target: "oracle2.as110.example.", Port: 8081},
+		}},
+	}
+	d := NewDiscoverer(DiscoveryConfig{Domain: "as110.example", Host: "path-oracle:8080", Resolver: resolver})
+	d.resolveHost = func(address string) (pan.UDPAddr, error) {
+		return pan.ParseUDPAddr("1-ff00:0:110,127.0.0.1:8080")
+	}
+
+	endpoints, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Endpoint{
+		{URL: "http://1-ff00:0:110,[10.0.0.1]:8080", Priority: 0},
+		{URL: "http://oracle1.as110.example:8080", Priority: 1},
+		{URL: "http://oracle2.as110.example:8081", Priority: 2},
+		{URL: "http://1-ff00:0:110,127.0.0.1:8080", Priority: 3},
+	}, endpoints)
+}
+
+func TestDiscoverCachesResult(t *testing.T) {
+	now := time.Now()
+	resolver := &fakeResolver{
+		srv: map[string][]*net.SRV{"_path-oracle._tcp.example": {{Target: "oracle.example.", Port: 80}}},
+	}
+	d := NewDiscoverer(DiscoveryConfig{Domain: "example", TTL: time.Minute, Resolver: resolver})
+	d.now = func() time.Time { return now }
+
+	_, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	_, err = d.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, resolver.lookups)
+
+	// once expired, the last result is kept while the records are gone
+	now = now.Add(2 * time.Minute)
+	resolver.srv = nil
+	endpoints, err := d.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "http://oracle.example:80", endpoints[0].URL)
+	assert.Equal(t, 4, resolver.lookups)
+}
+
+func TestDiscoverNothing(t *testing.T) {
+	d := NewDiscoverer(DiscoveryConfig{Domain: "example", Resolver: &fakeResolver{}})
+	_, err := d.Discover(context.Background())
+	assert.True(t, errors.Is(err, ErrNoOracleDiscovered))
+
+	_, err = NewOracleClient(WithDiscovery(d), WithTransport(http.DefaultTransport))
+	assert.True(t, errors.Is(err, ErrNoOracleDiscovered))
+}
+
+func TestClientPrefersDiscoveredOracles(t *testing.T) {
+	resolver := &fakeResolver{
+		srv: map[string][]*net.SRV{"_path-oracle._tcp.example": {{Target: "oracle.example.", Port: 80}}},
+	}
+	c, err := NewOracleClient(WithDiscovery(NewDiscoverer(DiscoveryConfig{Domain: "example", Resolver: resolver})),
+		WithBaseURL("http://fallback.example"), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	assert.Equal(t, "http://oracle.example:80", c.BaseURL())
+	assert.Len(t, c.Endpoints(), 2)
+
+	c, err = NewOracleClient(WithDiscovery(NewDiscoverer(DiscoveryConfig{Domain: "other", Resolver: resolver})),
+		WithBaseURL("http://fallback.example"), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	assert.Equal(t, "http://fallback.example", c.BaseURL())
+}
+
+func TestClientRefreshesDiscoveredOracles(t *testing.T) {
+	resolver := &fakeResolver{
+		srv: map[string][]*net.SRV{"_path-oracle._tcp.example": {{Target: "oracle1.example.", Port: 80}}},
+	}
+	d := NewDiscoverer(DiscoveryConfig{Domain: "example", TTL: time.Minute, Resolver: resolver})
+	now := time.Now()
+	d.now = func() time.Time { return now }
+	c, err := NewOracleClient(WithDiscovery(d), WithBaseURL("http://fallback.example"),
+		WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	fallback := c.endpoints[1]
+
+	resolver.srv["_path-oracle._tcp.example"] = []*net.SRV{{Target: "oracle2.example.", Port: 80}}
+	assert.Equal(t, "http://oracle1.example:80", c.BaseURL())
+
+	now = now.Add(time.Minute)
+	assert.Eventually(t, func() bool { return c.BaseURL() == "http://oracle2.example:80" }, time.Second,
+		time.Millisecond)
+	eps := c.currentEndpoints()
+	require.Len(t, eps, 2)
+	// the configured path oracle is kept along with its state
+	assert.Same(t, fallback, eps[1])
+}
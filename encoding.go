@@ -0,0 +1,153 @@
+package oclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"oclient/cbor"
+	"strings"
+)
+
+const (
+	gzipEncoding = "gzip"
+	// acceptPostHeader lists the media types a server accepts in request bodies.
+	acceptPostHeader = "Accept-Post"
+	// maxResponseSize limits the size of CBOR responses, which are read entirely before decoding.
+	maxResponseSize = 16 << 20
+)
+
+// EncodingConfig selects the compact encodings used once a path oracle advertised support for them.
+// Path oracles advertise accepted media types by the Accept-Post and accepted content encodings by the
+// Accept-Encoding header of their responses. Until then, and for path oracles advertising nothing, JSON is used.
+type EncodingConfig struct {
+	// CBOR encodes request bodies as CBOR and asks for CBOR responses.
+	CBOR bool
+	// Gzip compresses request bodies.
+	Gzip bool
+	// GzipMinSize is the size request bodies need to have to be compressed.
+	GzipMinSize int
+}
+
+// DefaultEncodingConfig uses CBOR and compresses request bodies of at least 512 bytes.
+var DefaultEncodingConfig = EncodingConfig{
+	CBOR:        true,
+	Gzip:        true,
+	GzipMinSize: 512,
+}
+
+// payload is an encoded request body.
+type payload struct {
+	body            []byte
+	contentType     string
+	contentEncoding string
+}
+
+// compact reports whether p uses an encoding the path oracle has to support.
+func (p *payload) compact() bool {
+	return p.contentType != jsonContentType || p.contentEncoding != ""
+}
+
+// negotiated returns the encodings an endpoint advertised support for.
+func (e *endpoint) negotiated() (cbor, gzip bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.acceptsCBOR, e.acceptsGzip
+}
+
+func (e *endpoint) setNegotiated(cbor, gzip bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.acceptsCBOR, e.acceptsGzip = cbor, gzip
+}
+
+// encode encodes in using the compact encodings the endpoint advertised, if enabled.
+func (c *OracleClient) encode(ep *endpoint, in interface{}) (*payload, error) {
+	var useCBOR, useGzip bool
+	if c.encoding != nil {
+		useCBOR, useGzip = ep.negotiated()
+		useCBOR = useCBOR && c.encoding.CBOR
+		useGzip = useGzip && c.encoding.Gzip
+	}
+
+	p := &payload{contentType: jsonContentType}
+	var err error
+	if useCBOR {
+		p.body, err = cbor.Marshal(in)
+		p.contentType = cbor.ContentType
+	} else {
+		p.body, err = json.Marshal(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if useGzip && len(p.body) >= c.encoding.GzipMinSize {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(p.body)
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		p.body = buf.Bytes()
+		p.contentEncoding = gzipEncoding
+	}
+	return p, nil
+}
+
+// negotiate records the encodings advertised by a successful response of the endpoint.
+func (c *OracleClient) negotiate(ep *endpoint, res *http.Response) {
+	if c.encoding == nil || res.StatusCode < 200 || res.StatusCode > 299 || ep.advertisedEncodings() {
+		return
+	}
+	acceptsCBOR, acceptsGzip := false, false
+	for _, mediaType := range headerTokens(res.Header, acceptPostHeader) {
+		if mediaType == cbor.ContentType {
+			acceptsCBOR = true
+		}
+	}
+	for _, encoding := range headerTokens(res.Header, "Accept-Encoding") {
+		if encoding == gzipEncoding {
+			acceptsGzip = true
+		}
+	}
+	if cbor, gzip := ep.negotiated(); cbor != acceptsCBOR || gzip != acceptsGzip {
+		c.logger.Debugw("path oracle encodings changed", "endpoint", ep.baseURL, "cbor", acceptsCBOR,
+			"gzip", acceptsGzip)
+		ep.setNegotiated(acceptsCBOR, acceptsGzip)
+	}
+}
+
+// decode decodes a response body into out according to its content type.
+func decode(res *http.Response, out interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != cbor.ContentType {
+		return json.NewDecoder(res.Body).Decode(out)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxResponseSize {
+		return fmt.Errorf("CBOR response exceeds %d bytes", maxResponseSize)
+	}
+	return cbor.Unmarshal(data, out)
+}
+
+// headerTokens returns the comma separated values of a header without parameters, in lower case.
+func headerTokens(h http.Header, key string) []string {
+	var tokens []string
+	for _, value := range h.Values(key) {
+		for _, token := range strings.Split(value, ",") {
+			if i := strings.Index(token, ";"); i >= 0 {
+				token = token[:i]
+			}
+			if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
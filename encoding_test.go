@@ -0,0 +1,82 @@
+package oclient
+
+import (
+	"compress/gzip"
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"oclient/cbor"
+	"testing"
+)
+
+func TestEncodingIsNegotiated(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	var contentTypes, contentEncodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		contentEncodings = append(contentEncodings, r.Header.Get("Content-Encoding"))
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == gzipEncoding {
+			gz, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = gz
+		}
+		raw, err := io.ReadAll(body)
+		require.NoError(t, err)
+		var q server.ScoringQuery
+		if r.Header.Get("Content-Type") == cbor.ContentType {
+			require.NoError(t, cbor.Unmarshal(raw, &q))
+		}
+
+		w.Header().Set(acceptPostHeader, "application/json, application/cbor")
+		w.Header().Set("Accept-Encoding", "gzip")
+		w.Header().Set("Content-Type", cbor.ContentType)
+		data, _ := cbor.Marshal(server.ScoringResponse{
+			dst: {{Fingerprint: "fp", Scores: map[string]float64{"throughput": 42}}},
+		})
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithEncoding(EncodingConfig{CBOR: true, Gzip: true}))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		res, err := c.FetchScoresContext(context.Background(), federationQuery)
+		require.NoError(t, err)
+		assert.Equal(t, 42., res[dst][0].Scores["throughput"])
+	}
+	assert.Equal(t, []string{jsonContentType, cbor.ContentType}, contentTypes)
+	assert.Equal(t, []string{"", gzipEncoding}, contentEncodings)
+}
+
+func TestEncodingFallsBackToJSON(t *testing.T) {
+	var contentTypes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		if r.Header.Get("Content-Type") != jsonContentType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if len(contentTypes) == 1 {
+			w.Header().Set(acceptPostHeader, cbor.ContentType)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithEncoding(DefaultEncodingConfig))
+	require.NoError(t, err)
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+	assert.NoError(t, c.ReportStats(report))
+	assert.NoError(t, c.ReportStats(report))
+	assert.NoError(t, c.ReportStats(report))
+	assert.Equal(t, []string{jsonContentType, cbor.ContentType, jsonContentType, jsonContentType}, contentTypes)
+}
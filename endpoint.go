@@ -0,0 +1,351 @@
+package oclient
+
+import (
+	"context"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is one of several path oracles a client talks to.
+type Endpoint struct {
+	// URL is the base URL of the path oracle, see WithBaseURL.
+	URL string
+	// Priority orders endpoints for failover, endpoints with lower values are preferred.
+	Priority int
+	// Confidence weighs the scores of this endpoint when merging them using MergeMaxConfidence.
+	Confidence float64
+}
+
+// MergeStrategy decides how scores are fetched from several endpoints.
+type MergeStrategy int
+
+const (
+	// MergeFirstWins fetches scores from the preferred reachable endpoint only, failing over to the next
+	// endpoint if it is unavailable.
+	MergeFirstWins MergeStrategy = iota
+	// MergeAverage fetches scores from all endpoints and averages the scores of paths scored by several of them.
+	MergeAverage
+	// MergeMaxConfidence fetches scores from all endpoints and uses the score of the endpoint with the highest
+	// Confidence for paths scored by several of them.
+	MergeMaxConfidence
+)
+
+func (m MergeStrategy) String() string {
+	switch m {
+	case MergeFirstWins:
+		return "first-wins"
+	case MergeAverage:
+		return "average"
+	case MergeMaxConfidence:
+		return "max-confidence"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseMergeStrategy parses the name of a MergeStrategy as returned by its String method.
+func ParseMergeStrategy(s string) (MergeStrategy, error) {
+	for _, m := range []MergeStrategy{MergeFirstWins, MergeAverage, MergeMaxConfidence} {
+		if strings.EqualFold(s, m.String()) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown merge strategy %q, expected one of first-wins, average or max-confidence", s)
+}
+
+// HealthCheckConfig configures the background probes of endpoints set by WithHealthCheck.
+type HealthCheckConfig struct {
+	// Interval between two probes of an endpoint.
+	Interval time.Duration
+	// Timeout of a single probe.
+	Timeout time.Duration
+}
+
+// EndpointStatus describes the health of an endpoint.
+type EndpointStatus struct {
+	URL     string
+	Healthy bool
+	Breaker BreakerState
+}
+
+type endpoint struct {
+	Endpoint
+	baseURL *url.URL
+	httpc   *http.Client
+	// streamc is used for long-lived streams, which must not be limited by the request timeout
+	streamc *http.Client
+	breaker *circuitBreaker
+
+	mutex   sync.Mutex
+	healthy bool
+	// acceptsCBOR and acceptsGzip are the request encodings the endpoint advertised
+	acceptsCBOR bool
+	acceptsGzip bool
+
+	// capsMutex guards the capabilities, which are cached in caps, or capsErr if incompatible. The error of the
+	// last capsFailures failed queries is cached in capsFailure until capsRetryAt. capsFlights coalesces their
+	// queries.
+	capsMutex    sync.Mutex
+	caps         *Capabilities
+	capsErr      error
+	capsFailure  error
+	capsFailures int
+	capsRetryAt  time.Time
+	capsFlights  flightGroup
+}
+
+func (e *endpoint) isHealthy() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.healthy
+}
+
+// setHealthy updates the health of e and reports whether it changed.
+func (e *endpoint) setHealthy(healthy bool) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	changed := e.healthy != healthy
+	e.healthy = healthy
+	return changed
+}
+
+// url appends the escaped path of an API endpoint to the base URL.
+func (e *endpoint) url(escapedPath string) string {
+	return e.baseURL.String() + escapedPath
+}
+
+// currentEndpoints returns the endpoints by priority. The slice is replaced, never modified, when discovered path
+// oracles change, callers use the returned slice throughout an operation.
+func (c *OracleClient) currentEndpoints() []*endpoint {
+	c.rediscover()
+	c.endpointsMutex.RLock()
+	defer c.endpointsMutex.RUnlock()
+	return c.endpoints
+}
+
+// candidates returns the endpoints in the order they are tried: healthy endpoints before unhealthy ones,
+// each by priority.
+func (c *OracleClient) candidates() []*endpoint {
+	endpoints := c.currentEndpoints()
+	if len(endpoints) == 1 {
+		return endpoints
+	}
+	eps := make([]*endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		if ep.isHealthy() {
+			eps = append(eps, ep)
+		}
+	}
+	for _, ep := range endpoints {
+		if !ep.isHealthy() {
+			eps = append(eps, ep)
+		}
+	}
+	return eps
+}
+
+// Endpoints returns the status of all endpoints by priority.
+func (c *OracleClient) Endpoints() []EndpointStatus {
+	eps := c.currentEndpoints()
+	status := make([]EndpointStatus, len(eps))
+	for i, ep := range eps {
+		status[i] = EndpointStatus{URL: ep.baseURL.String(), Healthy: ep.isHealthy(), Breaker: ep.breaker.currentState()}
+	}
+	return status
+}
+
+// fanOut calls fn for all endpoints in eps concurrently and returns their errors in the same order, or just
+// ErrNoOracle if there are no endpoints.
+func fanOut(eps []*endpoint, fn func(i int, ep *endpoint) error) []error {
+	if len(eps) == 0 {
+		return []error{ErrNoOracle}
+	}
+	errs := make([]error, len(eps))
+	if len(eps) == 1 {
+		errs[0] = fn(0, eps[0])
+		return errs
+	}
+	var wg sync.WaitGroup
+	for i, ep := range eps {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			errs[i] = fn(i, ep)
+		}(i, ep)
+	}
+	wg.Wait()
+	return errs
+}
+
+// anySucceeded returns nil if any endpoint of eps succeeded, otherwise the error of the preferred endpoint.
+// Errors of single endpoints are logged.
+func (c *OracleClient) anySucceeded(eps []*endpoint, errs []error, msg string) error {
+	succeeded := false
+	var first error
+	for i, err := range errs {
+		if err == nil {
+			succeeded = true
+			continue
+		}
+		if len(errs) > 1 {
+			c.logger.Warnw(msg, "endpoint", eps[i].baseURL, "error", err)
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	if succeeded {
+		return nil
+	}
+	return first
+}
+
+// checkHealth probes all endpoints every interval until the client is closed.
+func (c *OracleClient) checkHealth(config HealthCheckConfig) {
+	defer close(c.healthDone)
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		for _, ep := range c.currentEndpoints() {
+			err := c.probe(context.Background(), ep, config.Timeout)
+			if ep.setHealthy(err == nil) {
+				c.logger.Infow("path oracle health changed", "endpoint", ep.baseURL, "healthy", err == nil, "error", err)
+			}
+		}
+		select {
+		case <-c.stopHealth:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PingResult is the outcome of probing an endpoint.
+type PingResult struct {
+	URL string
+	// RTT is the time until the endpoint responded.
+	RTT time.Duration
+	// Err is nil if the endpoint is healthy.
+	Err error
+}
+
+// Ping probes all endpoints like the health check and updates their health. The results are in the order
+// of Endpoints.
+func (c *OracleClient) Ping(ctx context.Context) []PingResult {
+	eps := c.currentEndpoints()
+	results := make([]PingResult, len(eps))
+	fanOut(eps, func(i int, ep *endpoint) error {
+		start := time.Now()
+		err := c.probe(ctx, ep, 0)
+		results[i] = PingResult{URL: ep.baseURL.String(), RTT: time.Since(start), Err: err}
+		ep.setHealthy(err == nil)
+		return err
+	})
+	return results
+}
+
+// probe returns nil if the endpoint answers a request for its services without a server error. The scoring
+// services list of the path oracle is used to probe its health.
+func (c *OracleClient) probe(parent context.Context, ep *endpoint, timeout time.Duration) error {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(parent, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(parent)
+	}
+	defer cancel()
+	go func() {
+		// abort the probe when the client is closed
+		select {
+		case <-c.stopHealth:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := c.newRequest(ctx, http.MethodGet, ep.url(ep.cachedPaths().Services), nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.send(ep, opHealth, ep.httpc, req, 0)
+	if err != nil {
+		return &ConnectionError{Err: err}
+	}
+	defer func() {
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}()
+	if res.StatusCode >= 500 {
+		return checkResponse(res, nil)
+	}
+	return nil
+}
+
+// weightedResponse is the response of an endpoint along with its confidence.
+type weightedResponse struct {
+	res        server.ScoringResponse
+	confidence float64
+}
+
+// mergeScores combines the responses of several endpoints, ordered by priority, according to strategy.
+func mergeScores(strategy MergeStrategy, responses []weightedResponse) server.ScoringResponse {
+	type candidate struct {
+		sum, n     float64
+		best, conf float64
+	}
+	merged := make(map[addr.IA]map[oracle.PathFingerprint]map[string]*candidate)
+	for _, wr := range responses {
+		for dst, fpScores := range wr.res {
+			if merged[dst] == nil {
+				merged[dst] = make(map[oracle.PathFingerprint]map[string]*candidate)
+			}
+			for _, fs := range fpScores {
+				if merged[dst][fs.Fingerprint] == nil {
+					merged[dst][fs.Fingerprint] = make(map[string]*candidate)
+				}
+				for service, score := range fs.Scores {
+					c := merged[dst][fs.Fingerprint][service]
+					if c == nil {
+						c = &candidate{best: score, conf: wr.confidence}
+						merged[dst][fs.Fingerprint][service] = c
+					} else if wr.confidence > c.conf {
+						c.best, c.conf = score, wr.confidence
+					}
+					c.sum += score
+					c.n++
+				}
+			}
+		}
+	}
+
+	res := make(server.ScoringResponse, len(merged))
+	for dst, paths := range merged {
+		fpScores := make([]server.FingerprintScores, 0, len(paths))
+		for fp, services := range paths {
+			scores := make(map[string]float64, len(services))
+			for service, c := range services {
+				if strategy == MergeAverage {
+					scores[service] = c.sum / c.n
+				} else {
+					scores[service] = c.best
+				}
+			}
+			fpScores = append(fpScores, server.FingerprintScores{Fingerprint: fp, Scores: scores})
+		}
+		sort.Slice(fpScores, func(i, j int) bool { return fpScores[i].Fingerprint < fpScores[j].Fingerprint })
+		res[dst] = fpScores
+	}
+	return res
+}
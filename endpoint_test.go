@@ -0,0 +1,216 @@
+package oclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var federationQuery = server.ScoringQuery{
+	Queries: map[string][]services.ServiceName{addr.IA{I: 1, A: 13}.String(): {"throughput"}},
+}
+
+// scoringServer answers scoring requests with score for path fp, all other requests with status.
+func scoringServer(fp oracle.PathFingerprint, score float64, status int, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.URL.Path != defaultPaths.Scorings || status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		json.NewEncoder(w).Encode(server.ScoringResponse{
+			addr.IA{I: 1, A: 13}: {{Fingerprint: fp, Scores: map[string]float64{"throughput": score}}},
+		})
+	}))
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	for _, m := range []MergeStrategy{MergeFirstWins, MergeAverage, MergeMaxConfidence} {
+		parsed, err := ParseMergeStrategy(m.String())
+		assert.NoError(t, err)
+		assert.Equal(t, m, parsed)
+	}
+	_, err := ParseMergeStrategy("median")
+	assert.Error(t, err)
+}
+
+func TestMergeScores(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	responses := []weightedResponse{
+		{res: server.ScoringResponse{dst: {
+			{Fingerprint: "a", Scores: map[string]float64{"throughput": 10}},
+		}}, confidence: 0.5},
+		{res: server.ScoringResponse{dst: {
+			{Fingerprint: "b", Scores: map[string]float64{"throughput": 5}},
+			{Fingerprint: "a", Scores: map[string]float64{"throughput": 20}},
+		}}, confidence: 0.9},
+	}
+
+	avg := NewScoreSet(mergeScores(MergeAverage, responses))
+	assert.Equal(t, map[oracle.PathFingerprint]float64{"a": 15, "b": 5}, avg.Service(dst, "throughput"))
+
+	conf := NewScoreSet(mergeScores(MergeMaxConfidence, responses))
+	assert.Equal(t, map[oracle.PathFingerprint]float64{"a": 20, "b": 5}, conf.Service(dst, "throughput"))
+}
+
+func TestFetchScoresFailsOver(t *testing.T) {
+	var primaryRequests, secondaryRequests int32
+	primary := scoringServer("a", 1, http.StatusServiceUnavailable, &primaryRequests)
+	defer primary.Close()
+	secondary := scoringServer("b", 2, http.StatusOK, &secondaryRequests)
+	defer secondary.Close()
+
+	c, err := NewOracleClient(WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: secondary.URL, Priority: 2}, Endpoint{URL: primary.URL, Priority: 1}))
+	require.NoError(t, err)
+	assert.Equal(t, primary.URL, c.BaseURL())
+
+	res, err := c.FetchScores(federationQuery)
+	require.NoError(t, err)
+	_, ok := NewScoreSet(res).Score(addr.IA{I: 1, A: 13}, "b", "throughput")
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryRequests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&secondaryRequests))
+}
+
+func TestFetchScoresDoesNotFailOverOnRejection(t *testing.T) {
+	var primaryRequests, secondaryRequests int32
+	primary := scoringServer("a", 1, http.StatusBadRequest, &primaryRequests)
+	defer primary.Close()
+	secondary := scoringServer("b", 2, http.StatusOK, &secondaryRequests)
+	defer secondary.Close()
+
+	c, err := NewOracleClient(WithBaseURL(primary.URL), WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: secondary.URL}))
+	require.NoError(t, err)
+
+	_, err = c.FetchScores(federationQuery)
+	assert.True(t, errors.Is(err, ErrBadRequest))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&secondaryRequests))
+}
+
+func TestFetchScoresMergesEndpoints(t *testing.T) {
+	var requests int32
+	a := scoringServer("fp", 10, http.StatusOK, &requests)
+	defer a.Close()
+	b := scoringServer("fp", 30, http.StatusOK, &requests)
+	defer b.Close()
+	down := scoringServer("fp", 0, http.StatusServiceUnavailable, &requests)
+	defer down.Close()
+
+	c, err := NewOracleClient(WithTransport(http.DefaultTransport), WithMergeStrategy(MergeAverage),
+		WithEndpoints(Endpoint{URL: a.URL}, Endpoint{URL: b.URL}, Endpoint{URL: down.URL}))
+	require.NoError(t, err)
+
+	res, err := c.FetchScores(federationQuery)
+	require.NoError(t, err)
+	score, _ := NewScoreSet(res).Score(addr.IA{I: 1, A: 13}, "fp", "throughput")
+	assert.Equal(t, 20., score)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestReportStatsFansOut(t *testing.T) {
+	var okRequests, failedRequests int32
+	ok := scoringServer("", 0, http.StatusCreated, &okRequests)
+	defer ok.Close()
+	failing := scoringServer("", 0, http.StatusServiceUnavailable, &failedRequests)
+	defer failing.Close()
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+
+	c, err := NewOracleClient(WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: failing.URL}, Endpoint{URL: ok.URL}))
+	require.NoError(t, err)
+	assert.NoError(t, c.ReportStats(report))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&okRequests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failedRequests))
+
+	c, err = NewOracleClient(WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: failing.URL}, Endpoint{URL: failing.URL}))
+	require.NoError(t, err)
+	assert.True(t, errors.Is(c.ReportStats(report), ErrOracleUnavailable))
+}
+
+func TestReportStatsBatchFallsBackPerEndpoint(t *testing.T) {
+	var batches, singles int32
+	batching := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, defaultPaths.Batch, r.URL.Path)
+		atomic.AddInt32(&batches, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer batching.Close()
+	single := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == defaultPaths.Batch {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&singles, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer single.Close()
+	reports := []oracle.Report{
+		{DstIA: addr.IA{I: 1, A: 13}, PathFp: "a"},
+		{DstIA: addr.IA{I: 1, A: 13}, PathFp: "b"},
+	}
+
+	c, err := NewOracleClient(WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: batching.URL}, Endpoint{URL: single.URL}))
+	require.NoError(t, err)
+	assert.NoError(t, c.ReportStatsBatchContext(context.Background(), reports))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&batches))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&singles))
+
+	c, err = NewOracleClient(WithBaseURL(single.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	assert.Equal(t, ErrBatchUnsupported, c.ReportStatsBatchContext(context.Background(), reports))
+}
+
+func TestHealthCheckDemotesEndpoints(t *testing.T) {
+	var primaryRequests, secondaryRequests int32
+	primary := scoringServer("a", 1, http.StatusInternalServerError, &primaryRequests)
+	defer primary.Close()
+	secondary := scoringServer("b", 2, http.StatusOK, &secondaryRequests)
+	defer secondary.Close()
+
+	c, err := NewOracleClient(WithBaseURL(primary.URL), WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: secondary.URL}),
+		WithHealthCheck(HealthCheckConfig{Interval: time.Hour, Timeout: time.Second}))
+	require.NoError(t, err)
+	defer c.Close()
+
+	require.Eventually(t, func() bool { return !c.Endpoints()[0].Healthy }, time.Second, 5*time.Millisecond)
+	assert.True(t, c.Endpoints()[1].Healthy)
+	probes := atomic.LoadInt32(&primaryRequests)
+
+	_, err = c.FetchScores(federationQuery)
+	assert.NoError(t, err)
+	assert.Equal(t, probes, atomic.LoadInt32(&primaryRequests))
+}
+
+func TestPingProbesAllEndpoints(t *testing.T) {
+	var requests int32
+	healthy := scoringServer("a", 1, http.StatusOK, &requests)
+	defer healthy.Close()
+	broken := scoringServer("b", 1, http.StatusInternalServerError, &requests)
+	defer broken.Close()
+
+	c, err := NewOracleClient(WithTransport(http.DefaultTransport),
+		WithEndpoints(Endpoint{URL: healthy.URL, Priority: 1}, Endpoint{URL: broken.URL, Priority: 2}))
+	require.NoError(t, err)
+	results := c.Ping(context.Background())
+	require.Len(t, results, 2)
+	assert.Equal(t, healthy.URL, results[0].URL)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.False(t, c.Endpoints()[1].Healthy)
+}
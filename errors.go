@@ -0,0 +1,100 @@
+package oclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrOracleUnavailable indicates that the path oracle could not be reached or failed with a 5xx status code.
+	ErrOracleUnavailable = errors.New("path oracle unavailable")
+	// ErrBadRequest indicates that the path oracle considered a request malformed.
+	ErrBadRequest = errors.New("path oracle rejected malformed request")
+	// ErrUnauthorized indicates that the path oracle did not accept the credentials of a request.
+	ErrUnauthorized = errors.New("path oracle rejected credentials")
+	// ErrReportRejected indicates that the path oracle refused to accept a well-formed report,
+	// e.g. because it reports on an unknown path.
+	ErrReportRejected = errors.New("path oracle rejected report")
+	// ErrSubscriptionUnsupported indicates that the path oracle does not push score updates.
+	ErrSubscriptionUnsupported = errors.New("path oracle does not support score subscriptions")
+	// ErrBatchUnsupported indicates that the path oracle does not accept batches of reports.
+	ErrBatchUnsupported = errors.New("path oracle does not support batch reports")
+	// ErrReportSuppressed indicates that a report was deliberately not submitted to spare the path oracle, e.g. by
+	// a rate limit.
+	ErrReportSuppressed = errors.New("report suppressed by client")
+	// ErrIncompatibleOracle indicates that the capabilities of the path oracle rule out talking to it, e.g. because
+	// it speaks none of the APIVersions.
+	ErrIncompatibleOracle = errors.New("path oracle is incompatible with client")
+	// ErrServiceUnsupported indicates that the path oracle does not offer any of the queried scoring services.
+	ErrServiceUnsupported = errors.New("path oracle does not offer scoring service")
+	// ErrNoOracle indicates that the client was created without any path oracle to talk to.
+	ErrNoOracle = errors.New("no path oracle configured")
+)
+
+// maxErrorBodySize limits how much of an error response is kept in a ResponseError.
+const maxErrorBodySize = 4 << 10
+
+// ResponseError is returned for non 2xx responses of the path oracle. It wraps ErrOracleUnavailable,
+// ErrBadRequest, ErrUnauthorized or ErrReportRejected depending on the status code.
+type ResponseError struct {
+	StatusCode int
+	// Body is the (possibly truncated) error message sent by the path oracle.
+	Body string
+	Err  error
+}
+
+func (e *ResponseError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%v: status %d", e.Err, e.StatusCode)
+	}
+	return fmt.Sprintf("%v: status %d: %s", e.Err, e.StatusCode, e.Body)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// ConnectionError is returned if a request did not yield a response of the path oracle.
+// It matches ErrOracleUnavailable and wraps the underlying transport error.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrOracleUnavailable, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ConnectionError) Is(target error) bool {
+	return target == ErrOracleUnavailable
+}
+
+// checkResponse turns non 2xx responses into a ResponseError. 4xx responses other than
+// 400 Bad Request and 401 Unauthorized wrap rejected.
+func checkResponse(res *http.Response, rejected error) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	resErr := &ResponseError{StatusCode: res.StatusCode, Body: strings.TrimSpace(string(body))}
+	switch {
+	case res.StatusCode >= 500:
+		resErr.Err = ErrOracleUnavailable
+	case res.StatusCode == http.StatusBadRequest:
+		resErr.Err = ErrBadRequest
+	case res.StatusCode == http.StatusUnauthorized:
+		resErr.Err = ErrUnauthorized
+	case res.StatusCode >= 400:
+		resErr.Err = rejected
+	default:
+		resErr.Err = fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return resErr
+}
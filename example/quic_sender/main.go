@@ -1,18 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/tls"
+	"errors"
 	"flag"
 	"github.com/lucas-clemente/quic-go"
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"inet.af/netaddr"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"oclient"
+	"oclient/privacy"
+	"oclient/reporting"
 	"oclient/selectors"
+	"oclient/signing"
 	"oclient/tracers"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -20,6 +34,36 @@ import (
 func main() {
 	var (
 		remoteAddr, selectorName string
+		oracleURL                string
+		oracleFallbacks          string
+		discoveryConfig          = oclient.DefaultDiscoveryConfig
+		oracleMerge              string
+		oracleHealthInterval     time.Duration
+		oracleCompact            bool
+		oracleCapabilities       bool
+		metricsListen            string
+		traceExporter            string
+		traceEndpoint            string
+		oracleNetwork            string
+		oracleCAFile             string
+		oracleCertFile           string
+		oracleKeyFile            string
+		oracleToken              string
+		oracleHMACKeyID          string
+		oracleHMACKeyFile        string
+		signingKeyFile           string
+		signingKeyID             string
+		oracleTimeout            time.Duration
+		oracleRetryPolicy        = oclient.DefaultRetryPolicy
+		scoreCacheConfig         = oclient.DefaultScoreCacheConfig
+		spoolConfig              = reporting.DefaultSpoolConfig
+		reporterConfig           = reporting.DefaultReporterConfig
+		limiterConfig            = reporting.DefaultLimiterConfig
+		privacyConfig            = privacy.DefaultConfig
+		privacySrcIA             string
+		throughputSensitivity    float64
+		validateReports          bool
+		quarantineFile           string
 		disableMTUDiscovery      bool
 		sendingDur               time.Duration
 		reportingConfig          tracers.ReportingConfig
@@ -29,14 +73,62 @@ func main() {
 
 	flag.StringVar(&remoteAddr, "remote", "", "remote address, where data will be send to")
 	flag.StringVar(&selectorName, "selector", "", "selector which will be used for path selection")
+	flag.StringVar(&oracleURL, "oracle", defaultOracleURL(), "base URL of the path oracle, defaults to http://$PATH_ORACLE")
+	flag.StringVar(&oracleFallbacks, "oracleFallbacks", "", "comma separated base URLs of further path oracles, tried in order if -oracle is unavailable")
+	flag.StringVar(&discoveryConfig.Domain, "oracleDomain", "", "DNS domain of the local AS to discover path oracles in by SRV and TXT records - empty to disable")
+	flag.StringVar(&discoveryConfig.Host, "oracleHost", "", "host name of the path oracle resolved by hosts files and RAINS, e.g. path-oracle:8080 - empty to disable")
+	flag.StringVar(&oracleMerge, "oracleMerge", oclient.MergeFirstWins.String(), "how path scorings of several oracles are combined: first-wins, average or max-confidence")
+	flag.DurationVar(&oracleHealthInterval, "oracleHealth", 0, "interval the health of the path oracles is probed in - 0 to disable")
+	flag.BoolVar(&oracleCompact, "oracleCompact", false, "send CBOR encoded, gzip compressed requests to path oracles supporting them")
+	flag.BoolVar(&oracleCapabilities, "oracleCapabilities", true, "query the API versions, URL layout, encodings and services of the path oracle before talking to it")
+	flag.StringVar(&oracleNetwork, "oracleNetwork", oclient.NetworkSCION.String(), "network the path oracle is reached by: scion, ip or auto (scion for SCION addresses, ip otherwise)")
+	flag.StringVar(&oracleCAFile, "oracleCA", "", "PEM file with CA certificates trusted for an https path oracle - empty to use the system roots")
+	flag.StringVar(&oracleCertFile, "oracleCert", "", "PEM file with a client certificate presented to an https path oracle")
+	flag.StringVar(&oracleKeyFile, "oracleKey", "", "PEM file with the private key of -oracleCert")
+	flag.StringVar(&oracleToken, "oracleToken", os.Getenv("PATH_ORACLE_TOKEN"), "bearer token authenticating requests to the path oracle, defaults to $PATH_ORACLE_TOKEN")
+	flag.StringVar(&oracleHMACKeyID, "oracleHMACKeyID", "", "ID of the key in -oracleHMACKey")
+	flag.StringVar(&oracleHMACKeyFile, "oracleHMACKey", "", "file with a shared key used to sign requests to the path oracle")
+	flag.StringVar(&signingKeyFile, "signingKey", "", "PEM file with an Ed25519 private key reports are signed with - empty to send unsigned reports")
+	flag.StringVar(&signingKeyID, "signingKeyID", "", "ID of -signingKey, defaults to a hash of the public key")
+	flag.DurationVar(&oracleTimeout, "oracleTimeout", 10*time.Second, "timeout of a single request to the path oracle")
+	flag.IntVar(&oracleRetryPolicy.MaxAttempts, "oracleAttempts", oracleRetryPolicy.MaxAttempts, "maximum attempts of a failing request to the path oracle")
 	flag.BoolVar(&disableMTUDiscovery, "disableMTUDiscovery", true, "disable QUICs path MTU discovery")
 	flag.DurationVar(&sendingDur, "sendingDur", 2*time.Minute, "duration in which data will be uploaded")
 
 	flag.DurationVar(&reportingConfig.MinIntervalForReport, "rMinInterval", 0, "report connection stats collected representing a minimum period of time")
 	flag.DurationVar(&reportingConfig.ReportingInterval, "rInterval", 5*time.Minute, "continuous reporting of connection stats to the oracle - 0 to disable")
 	flag.BoolVar(&reportingConfig.ReportOnPathChange, "rOnPathChange", true, "report connection stats to oracle when the path changed")
-	flag.DurationVar(&oracleSelectorConfig.FetchScoresInterval, "fInterval", 10*time.Minute, "[oracle selector only] interval after path scorings are refetched")
+	flag.DurationVar(&reportingConfig.ReportTimeout, "rTimeout", 30*time.Second, "maximum time spent submitting a single report to the oracle - 0 to wait indefinitely")
+	flag.DurationVar(&oracleSelectorConfig.FetchScoresInterval, "fInterval", 10*time.Minute, "[oracle selector only] interval after path scorings are refetched - 0 to fetch them once, without polling or subscribing")
+	flag.DurationVar(&oracleSelectorConfig.FetchScoresTimeout, "fTimeout", 5*time.Second, "[oracle selector only] maximum time spent fetching path scorings, bounds the dial - 0 to wait indefinitely")
 
+	flag.BoolVar(&oracleSelectorConfig.DisableSubscription, "fPoll", false, "[oracle selector only] poll path scorings every -fInterval even if the oracle pushes updates")
+	flag.DurationVar(&scoreCacheConfig.TTL, "fCacheTTL", scoreCacheConfig.TTL, "time fetched path scorings are reused - 0 to disable caching")
+	flag.DurationVar(&scoreCacheConfig.StaleWhileRevalidate, "fCacheStale", scoreCacheConfig.StaleWhileRevalidate, "time expired path scorings are still used while being refetched")
+	flag.IntVar(&reporterConfig.QueueSize, "rQueueSize", reporterConfig.QueueSize, "maximum number of reports waiting for submission")
+	flag.IntVar(&reporterConfig.Workers, "rWorkers", reporterConfig.Workers, "number of reports submitted concurrently")
+	flag.IntVar(&reporterConfig.BatchSize, "rBatchSize", reporterConfig.BatchSize, "maximum number of reports submitted in a single request")
+	flag.StringVar(&spoolConfig.Dir, "spoolDir", "", "directory to spool reports in until the oracle is reachable - empty to disable spooling")
+	flag.Int64Var(&spoolConfig.MaxBytes, "spoolMaxBytes", spoolConfig.MaxBytes, "maximum size of spooled reports")
+	flag.DurationVar(&spoolConfig.MaxAge, "spoolMaxAge", spoolConfig.MaxAge, "maximum age of spooled reports")
+	flag.Float64Var(&limiterConfig.DestinationRate, "rLimitDst", limiterConfig.DestinationRate, "reports per second sustained to a destination IA by all connections - 0 for no limit")
+	flag.IntVar(&limiterConfig.DestinationBurst, "rLimitDstBurst", limiterConfig.DestinationBurst, "reports to a destination IA allowed at once")
+	flag.Float64Var(&limiterConfig.PathRate, "rLimitPath", limiterConfig.PathRate, "reports per second sustained on a path by all connections - 0 for no limit")
+	flag.IntVar(&limiterConfig.PathBurst, "rLimitPathBurst", limiterConfig.PathBurst, "reports on a path allowed at once")
+	flag.Float64Var(&limiterConfig.StableFraction, "rSampleStable", limiterConfig.StableFraction, "fraction of reports submitted on paths with a stable throughput - 0 or 1 to submit all")
+	flag.Float64Var(&limiterConfig.StableTolerance, "rSampleTolerance", limiterConfig.StableTolerance, "relative deviation from the average throughput of a path still considered stable")
+
+	flag.BoolVar(&validateReports, "validateReports", true, "do not submit reports with missing fingerprints or NaN, infinite or out of range properties")
+	flag.StringVar(&quarantineFile, "quarantineFile", "", "file invalid reports are appended to along with the reason - empty to discard them")
+	flag.StringVar(&privacySrcIA, "privacySrcIA", privacy.SrcIAKeep.String(), "source IA kept in spooled and quarantined reports and the interval file: keep, coarsen (ISD only) or drop - the oracle learns the source AS from the connection regardless")
+	flag.DurationVar(&privacyConfig.DurationBucket, "privacyDurationBucket", 0, "round report durations and timestamps to multiples of this - 0 to keep them exact")
+	flag.Float64Var(&privacyConfig.Epsilon, "privacyEpsilon", 0, "privacy loss of a report, Laplace noise is added to its throughput - 0 to disable noise")
+	flag.Float64Var(&throughputSensitivity, "privacyThroughputSensitivity", privacyConfig.Sensitivity["throughput"], "maximum throughput (bytes/s) a connection contributes, scales the noise")
+	flag.Float64Var(&privacyConfig.Budget, "privacyBudget", privacyConfig.Budget, "total privacy loss per destination and -privacyBudgetPeriod, further reports are dropped - 0 for no limit")
+	flag.DurationVar(&privacyConfig.BudgetPeriod, "privacyBudgetPeriod", privacyConfig.BudgetPeriod, "period after which the privacy budget of a destination is renewed")
+	flag.StringVar(&metricsListen, "metricsListen", "", "address to serve Prometheus metrics of the oracle interaction on, e.g. 127.0.0.1:9100 - empty to disable")
+	flag.StringVar(&traceExporter, "traceExporter", "none", "exporter of OpenTelemetry spans of dialing, path selection and oracle interaction: none, stdout or otlp")
+	flag.StringVar(&traceEndpoint, "traceEndpoint", "", "host:port of the OTLP/HTTP collector spans are exported to, defaults to $OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318")
 	flag.StringVar(&csvWritingConfig.SummaryFile, "summaryFile", "", "csv file to write a connection lifetime stats to")
 	flag.StringVar(&csvWritingConfig.IntervalFile, "intervalFile", "", "csv file to write a interval connection stats to")
 	flag.Parse()
@@ -45,7 +137,176 @@ func main() {
 	defer logger.Sync()
 	slogger := logger.Sugar()
 
-	selector := getSelector(selectorName, slogger, oracleSelectorConfig)
+	network, err := oclient.ParseNetwork(oracleNetwork)
+	if err != nil {
+		slogger.Fatalw("error parsing oracle network", "error", err)
+	}
+	merge, err := oclient.ParseMergeStrategy(oracleMerge)
+	if err != nil {
+		slogger.Fatalw("error parsing oracle merge strategy", "error", err)
+	}
+	shutdownTracing, err := setupTracing(traceExporter, traceEndpoint)
+	if err != nil {
+		slogger.Fatalw("error setting up tracing", "error", err, "exporter", traceExporter)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slogger.Warnw("could not export all spans", "error", err)
+		}
+	}()
+	var metrics *oclient.Metrics
+	if metricsListen != "" {
+		registry := prometheus.NewRegistry()
+		if metrics, err = oclient.NewMetrics(registry); err != nil {
+			slogger.Fatalw("error registering metrics", "error", err)
+		}
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+			slogger.Errorw("metrics server stopped", "error", http.ListenAndServe(metricsListen, mux))
+		}()
+	}
+	clientOpts := []oclient.Option{
+		oclient.WithBaseURL(oracleURL),
+		oclient.WithNetwork(network),
+		oclient.WithTimeout(oracleTimeout),
+		oclient.WithRetryPolicy(oracleRetryPolicy),
+		oclient.WithCircuitBreaker(oclient.DefaultCircuitBreakerConfig),
+		oclient.WithLogger(slogger.With("component", "OracleClient")),
+		oclient.WithMergeStrategy(merge),
+		oclient.WithMetrics(metrics),
+	}
+	if oracleFallbacks != "" {
+		for i, fallback := range strings.Split(oracleFallbacks, ",") {
+			clientOpts = append(clientOpts,
+				oclient.WithEndpoints(oclient.Endpoint{URL: strings.TrimSpace(fallback), Priority: i + 1}))
+		}
+	}
+	if discoveryConfig.Domain != "" || discoveryConfig.Host != "" {
+		clientOpts = append(clientOpts, oclient.WithDiscovery(oclient.NewDiscoverer(discoveryConfig)))
+	}
+	if oracleCapabilities {
+		clientOpts = append(clientOpts, oclient.WithCapabilities())
+	}
+	if oracleCompact {
+		clientOpts = append(clientOpts, oclient.WithEncoding(oclient.DefaultEncodingConfig))
+	}
+	if validateReports {
+		var quarantine oclient.Quarantine
+		if quarantineFile != "" {
+			qf, err := reporting.NewQuarantineFile(quarantineFile)
+			if err != nil {
+				slogger.Fatalw("error opening quarantine file", "error", err, "file", quarantineFile)
+			}
+			defer qf.Close()
+			quarantine = qf
+		}
+		clientOpts = append(clientOpts, oclient.WithValidation(oclient.DefaultReportSchema, quarantine))
+	}
+	if oracleHealthInterval > 0 {
+		clientOpts = append(clientOpts, oclient.WithHealthCheck(
+			oclient.HealthCheckConfig{Interval: oracleHealthInterval, Timeout: oracleTimeout}))
+	}
+	if scoreCacheConfig.TTL > 0 {
+		clientOpts = append(clientOpts, oclient.WithScoreCache(scoreCacheConfig))
+	}
+	if oracleCAFile != "" {
+		clientOpts = append(clientOpts, oclient.WithCAFile(oracleCAFile))
+	}
+	if oracleCertFile != "" {
+		clientOpts = append(clientOpts, oclient.WithClientCertificate(oracleCertFile, oracleKeyFile))
+	}
+	if oracleToken != "" {
+		clientOpts = append(clientOpts, oclient.WithBearerToken(oracleToken))
+	}
+	if oracleHMACKeyFile != "" {
+		key, err := ioutil.ReadFile(oracleHMACKeyFile)
+		if err != nil {
+			slogger.Fatalw("error reading HMAC key", "error", err, "file", oracleHMACKeyFile)
+		}
+		clientOpts = append(clientOpts, oclient.WithHMACKey(oracleHMACKeyID, bytes.TrimSpace(key)))
+	}
+	oracleClient, err := oclient.NewOracleClient(clientOpts...)
+	if err != nil {
+		slogger.Fatalw("error creating oracle client", "error", err, "oracle", oracleURL)
+	}
+	defer oracleClient.Close()
+	if oracleCapabilities && oracleClient.BaseURL() != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), oracleTimeout)
+		_, err := oracleClient.Capabilities(ctx)
+		cancel()
+		if errors.Is(err, oclient.ErrIncompatibleOracle) {
+			slogger.Fatalw("path oracle is incompatible", "error", err, "oracle", oracleClient.BaseURL())
+		} else if err != nil {
+			// the capabilities are queried again by the first request
+			slogger.Warnw("error querying path oracle capabilities", "error", err, "oracle", oracleClient.BaseURL())
+		}
+	}
+
+	var reporter oclient.StatsReporter = oracleClient
+	if signingKeyFile != "" {
+		signer, err := signing.LoadSigner(signingKeyFile, signingKeyID)
+		if err != nil {
+			slogger.Fatalw("error loading signing key", "error", err, "file", signingKeyFile)
+		}
+		slogger.Infow("signing reports", "key_id", signer.KeyID)
+		reporter = signing.NewReporter(oracleClient, signer)
+	}
+	spoolConfig.Metrics = metrics
+	reporterConfig.Metrics = metrics
+	if spoolConfig.Dir != "" {
+		spool, err := reporting.NewSpool(reporter, spoolConfig, slogger.With("component", "Spool"))
+		if err != nil {
+			slogger.Fatalw("error opening report spool", "error", err, "dir", spoolConfig.Dir)
+		}
+		defer spool.Close()
+		reporter = spool
+	}
+	reporterConfig.SendTimeout = reportingConfig.ReportTimeout
+	queue := reporting.NewReporter(reporter, reporterConfig, slogger.With("component", "Reporter"))
+	defer func() {
+		var (
+			ctx    context.Context
+			cancel context.CancelFunc
+		)
+		if reportingConfig.ReportTimeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), reportingConfig.ReportTimeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		defer cancel()
+		if err := queue.Close(ctx); err != nil {
+			slogger.Warnw("could not submit all queued reports", "error", err, "dropped", queue.Dropped())
+		}
+	}()
+	reporter = queue
+
+	srcIAMode, err := privacy.ParseSrcIAMode(privacySrcIA)
+	if err != nil {
+		slogger.Fatalw("error parsing privacy source IA mode", "error", err)
+	}
+	privacyConfig.SrcIA = srcIAMode
+	csvWritingConfig.SrcIA = srcIAMode
+	privacyConfig.Sensitivity = map[string]float64{"throughput": throughputSensitivity}
+	privacyConfig.TimestampBucket = privacyConfig.DurationBucket
+	privacyConfig.Metrics = metrics
+	if privacyConfig.SrcIA != privacy.SrcIAKeep || privacyConfig.DurationBucket > 0 || privacyConfig.Epsilon > 0 {
+		// anonymize before queueing, so retries of spooled reports do not spend the privacy budget again
+		reporter = privacy.NewReporter(reporter, privacy.NewAnonymizer(privacyConfig))
+	}
+
+	// limit before anonymizing, so suppressed reports do not spend the privacy budget
+	limiterConfig.Metrics = metrics
+	limiter := reporting.NewLimiter(reporter, limiterConfig)
+	defer func() {
+		stats := limiter.Stats()
+		slogger.Infow("report limiter", "passed", stats.Passed, "rate_limited", stats.RateLimited, "sampled", stats.Sampled)
+	}()
+	reporter = limiter
+
+	selector := getSelector(selectorName, slogger, oracleClient, oracleSelectorConfig)
 	remote, err := pan.ParseUDPAddr(remoteAddr)
 	if err != nil {
 		slogger.Fatalw("error parsing remote address", "error", err, "remote_address", remoteAddr)
@@ -58,27 +319,31 @@ func main() {
 		"reportingConfig", reportingConfig,
 		"csvWritingConfig", csvWritingConfig,
 		"disableMTUDiscovery", disableMTUDiscovery)
-	_, _, err = runSender(slogger, remote, selector, sendingDur, reportingConfig, csvWritingConfig, disableMTUDiscovery)
+	_, _, err = runSender(slogger, remote, selector, oracleClient, reporter, sendingDur, reportingConfig, csvWritingConfig, disableMTUDiscovery)
 	if err != nil {
 		slogger.Fatalw("error running sender", "error", err)
 	}
 }
 
-func runSender(logger *zap.SugaredLogger, remote pan.UDPAddr, selector pan.Selector, dur time.Duration,
-	rConf tracers.ReportingConfig, csvConf tracers.CsvWritingConfig, disableMTUDiscovery bool) (time.Duration, int64, error) {
+func runSender(logger *zap.SugaredLogger, remote pan.UDPAddr, selector pan.Selector, oracleClient *oclient.OracleClient,
+	reporter oclient.StatsReporter, dur time.Duration, rConf tracers.ReportingConfig, csvConf tracers.CsvWritingConfig, disableMTUDiscovery bool) (time.Duration, int64, error) {
 
 	pathChan := make(chan *pan.Path)
 	bwTracer := tracers.BandwidthTracer{
 		ReportingConfig:  rConf,
 		Logger:           logger.With("tracers", "BandwidthTracer"),
 		CsvWritingConfig: csvConf,
+		OracleClient:     oracleClient,
+		Reporter:         reporter,
 		PathChan:         pathChan}
 
 	if pb, ok := selector.(oclient.PathPublisher); ok {
 		pb.SetPathChan(pathChan)
 	}
 
-	con, err := pan.DialQUIC(context.Background(), netaddr.IPPort{}, remote, nil, selector, "", &tls.Config{
+	ctx, span := otel.Tracer("quic_sender").Start(context.Background(), "pan.DialQUIC",
+		trace.WithAttributes(attribute.String("remote", remote.String())))
+	con, err := pan.DialQUIC(ctx, netaddr.IPPort{}, remote, nil, selector, "", &tls.Config{
 		//Certificates: quicutil.MustGenerateSelfSignedCert(),
 		InsecureSkipVerify: true,
 		NextProtos:         []string{"quic-test", "panapi-quic-test"},
@@ -86,6 +351,10 @@ func runSender(logger *zap.SugaredLogger, remote pan.UDPAddr, selector pan.Selec
 		Tracer:                  bwTracer,
 		DisablePathMTUDiscovery: disableMTUDiscovery,
 	})
+	if err == nil {
+		span.SetAttributes(attribute.String("local", con.LocalAddr().String()))
+	}
+	oclient.EndSpan(span, err)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -115,25 +384,34 @@ func runSender(logger *zap.SugaredLogger, remote pan.UDPAddr, selector pan.Selec
 	return time.Since(startWrite), 0, err
 }
 
-func getSelector(selector string, logger *zap.SugaredLogger, config selectors.OracleSelectorConfig) pan.Selector {
+func getSelector(selector string, logger *zap.SugaredLogger, oracleClient *oclient.OracleClient,
+	config selectors.OracleSelectorConfig) pan.Selector {
 	switch selector {
 	case "random":
-		return &selectors.RandomPathSelector{Logger: logger.With("selector", selector)}
+		return selectors.NewTracingSelector("RandomPathSelector",
+			&selectors.RandomPathSelector{Logger: logger.With("selector", selector)})
 	case "shortest":
-		return &selectors.ShortestPathSelector{Logger: logger.With("selector", selector)}
+		return selectors.NewTracingSelector("ShortestPathSelector",
+			&selectors.ShortestPathSelector{Logger: logger.With("selector", selector)})
 	case "oracle":
-		return selectors.NewThroughputPathSelector(config, logger.With("selector", selector))
+		return selectors.NewThroughputPathSelector(oracleClient, config, logger.With("selector", selector))
 	case "norm":
-		return &selectors.NormSelector{Logger: logger.With("selector", selector)}
+		return selectors.NewTracingSelector("NormSelector", &selectors.NormSelector{Logger: logger.With("selector", selector)})
 	case "ping":
-		return &pan.PingingSelector{
+		return selectors.NewTracingSelector("PingingSelector", &pan.PingingSelector{
 			Interval: 2 * time.Second,
-			Timeout:  time.Second}
+			Timeout:  time.Second})
 	case "constant":
-		return &selectors.ConstantPathSelector{Logger: logger.With("constant", selector)}
-	case "default":
-		return pan.NewDefaultSelector()
+		return selectors.NewTracingSelector("ConstantPathSelector",
+			&selectors.ConstantPathSelector{Logger: logger.With("constant", selector)})
 	default:
-		return pan.NewDefaultSelector()
+		return selectors.NewTracingSelector("DefaultSelector", pan.NewDefaultSelector())
+	}
+}
+
+func defaultOracleURL() string {
+	if location := os.Getenv("PATH_ORACLE"); location != "" {
+		return "http://" + location
 	}
+	return ""
 }
@@ -0,0 +1,20 @@
+// Package props reads the values of report properties, which are untyped once decoded from JSON or CBOR.
+package props
+
+// Number returns v as float64 if it is numeric. Numbers decoded from JSON are float64, those decoded from CBOR
+// integers int64 or uint64, and those set by tracers of any numeric type.
+func Number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
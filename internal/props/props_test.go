@@ -0,0 +1,18 @@
+package props
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	for _, v := range []interface{}{2.5, float32(2.5), 2, int64(2), uint64(2)} {
+		n, ok := Number(v)
+		assert.True(t, ok, "%T", v)
+		assert.InDelta(t, 2.5, n, 0.5, "%T", v)
+	}
+	for _, v := range []interface{}{nil, "2", true, []float64{2}} {
+		_, ok := Number(v)
+		assert.False(t, ok, "%T", v)
+	}
+}
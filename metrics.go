@@ -0,0 +1,147 @@
+package oclient
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const metricsNamespace = "path_oracle_client"
+
+// Operations labelling the requests to a path oracle.
+const (
+	opScores    = "scores"
+	opReport    = "report"
+	opBatch     = "batch"
+	opSubscribe = "subscribe"
+	opHealth    = "health"
+	// opCapabilities queries the capabilities of a path oracle
+	opCapabilities = "capabilities"
+)
+
+// Reasons labelling dropped reports.
+const (
+	DropQueueFull = "queue_full"
+	DropSpoolFull = "spool_full"
+	DropExpired   = "expired"
+	DropRejected  = "rejected"
+	DropCorrupt   = "corrupt"
+	DropFailed    = "failed"
+	// DropPrivacyBudget labels reports exceeding the privacy budget of their destination.
+	DropPrivacyBudget = "privacy_budget"
+	// DropInvalid labels reports violating the ReportSchema of the client.
+	DropInvalid = "invalid"
+	// DropRateLimited labels reports exceeding the rate limit of their destination or path.
+	DropRateLimited = "rate_limited"
+	// DropSampled labels reports left out by sampling paths with a stable throughput.
+	DropSampled = "sampled"
+)
+
+// Metrics are the Prometheus collectors instrumenting the interaction with path oracles. They are shared by
+// clients configured by WithMetrics and the reporting stages given them. All methods are no-ops on nil Metrics.
+type Metrics struct {
+	requests  *prometheus.CounterVec
+	duration  *prometheus.HistogramVec
+	inFlight  *prometheus.GaugeVec
+	bytesSent *prometheus.CounterVec
+	spooled   prometheus.Counter
+	dropped   *prometheus.CounterVec
+}
+
+// NewMetrics creates the collectors and registers them on registerer.
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help: "Requests to path oracles by endpoint, operation and HTTP status, " +
+				"error if no response was received and circuit_open if the request was not sent.",
+		}, []string{"endpoint", "operation", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time until the response headers of path oracles were received.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "operation"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_in_flight",
+			Help:      "Requests to path oracles waiting for a response.",
+		}, []string{"endpoint"}),
+		bytesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_bytes_total",
+			Help:      "Bytes of request bodies sent to path oracles.",
+		}, []string{"endpoint"}),
+		spooled: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reports_spooled_total",
+			Help:      "Reports persisted in the spool for later delivery.",
+		}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reports_dropped_total",
+			Help:      "Reports discarded before delivery by reason.",
+		}, []string{"reason"}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.duration, m.inFlight, m.bytesSent, m.spooled, m.dropped} {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ReportSpooled counts a report persisted in a spool.
+func (m *Metrics) ReportSpooled() {
+	if m == nil {
+		return
+	}
+	m.spooled.Inc()
+}
+
+// ReportDropped counts a report discarded for reason, e.g. DropQueueFull.
+func (m *Metrics) ReportDropped(reason string) {
+	if m == nil {
+		return
+	}
+	m.dropped.WithLabelValues(reason).Inc()
+}
+
+// circuitOpen counts a request to the endpoint not sent because its circuit breaker is open.
+func (m *Metrics) circuitOpen(ep *endpoint, operation string) {
+	if m == nil {
+		return
+	}
+	m.requests.WithLabelValues(ep.baseURL.String(), operation, "circuit_open").Inc()
+}
+
+// send sends req to the endpoint using httpc, recording the request as operation in the metrics and a span.
+func (c *OracleClient) send(ep *endpoint, operation string, httpc *http.Client, req *http.Request,
+	bodySize int) (*http.Response, error) {
+
+	span := traceRequest(ep, operation, req)
+	m := c.metrics
+	if m == nil {
+		res, err := httpc.Do(req)
+		endRequest(span, res, err)
+		return res, err
+	}
+	endpoint := ep.baseURL.String()
+	m.bytesSent.WithLabelValues(endpoint).Add(float64(bodySize))
+	inFlight := m.inFlight.WithLabelValues(endpoint)
+	inFlight.Inc()
+	defer inFlight.Dec()
+
+	start := time.Now()
+	res, err := httpc.Do(req)
+	endRequest(span, res, err)
+	m.duration.WithLabelValues(endpoint, operation).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	m.requests.WithLabelValues(endpoint, operation, status).Inc()
+	return res, err
+}
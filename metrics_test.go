@@ -0,0 +1,41 @@
+package oclient
+
+import (
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsRecordRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetrics(registry)
+	require.NoError(t, err)
+	_, err = NewMetrics(registry)
+	assert.Error(t, err, "collectors are registered twice")
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport), WithMetrics(metrics),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}))
+	require.NoError(t, err)
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "fp"}
+	assert.Error(t, c.ReportStats(report))
+	assert.ErrorIs(t, c.ReportStats(report), ErrCircuitOpen)
+
+	endpoint := c.BaseURL()
+	assert.Equal(t, 1., testutil.ToFloat64(metrics.requests.WithLabelValues(endpoint, opReport, "503")))
+	assert.Equal(t, 1., testutil.ToFloat64(metrics.requests.WithLabelValues(endpoint, opReport, "circuit_open")))
+	assert.Equal(t, 0., testutil.ToFloat64(metrics.inFlight.WithLabelValues(endpoint)))
+	assert.Greater(t, testutil.ToFloat64(metrics.bytesSent.WithLabelValues(endpoint)), 0.)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.duration))
+}
@@ -0,0 +1,222 @@
+package oclient
+
+import (
+	"crypto/tls"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const defaultUserAgent = "scion-path-oracle-client"
+
+// Option configures an OracleClient created by NewOracleClient.
+type Option func(o *options)
+
+type options struct {
+	baseURL    string
+	endpoints  []Endpoint
+	merge      MergeStrategy
+	health     *HealthCheckConfig
+	discovery  *Discoverer
+	transport  http.RoundTripper
+	network    Network
+	tlsConfig  *tls.Config
+	caFile     string
+	certFile   string
+	keyFile    string
+	auth       Authenticator
+	timeout    time.Duration
+	userAgent  string
+	logger     *zap.SugaredLogger
+	retry      RetryPolicy
+	breaker    *CircuitBreakerConfig
+	cache      *ScoreCacheConfig
+	encoding   *EncodingConfig
+	metrics    *Metrics
+	schema     *ReportSchema
+	quarantine Quarantine
+	caps       bool
+}
+
+func defaultOptions() options {
+	return options{
+		userAgent: defaultUserAgent,
+		logger:    zap.NewNop().Sugar(),
+	}
+}
+
+// WithBaseURL sets the location of the path oracle including scheme and an optional path prefix,
+// e.g. https://oracle.local:8080/api or http://1-ff00:0:110,[127.0.0.1]:8080 for an oracle reached via SCION.
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithEndpoints adds several path oracles. Scores are fetched according to the MergeStrategy set by
+// WithMergeStrategy, reports are submitted to all endpoints. An endpoint given by WithBaseURL is preferred
+// over all others.
+func WithEndpoints(endpoints ...Endpoint) Option {
+	return func(o *options) {
+		o.endpoints = append(o.endpoints, endpoints...)
+	}
+}
+
+// WithMergeStrategy decides how scores are fetched from several endpoints. By default, MergeFirstWins is used.
+func WithMergeStrategy(strategy MergeStrategy) Option {
+	return func(o *options) {
+		o.merge = strategy
+	}
+}
+
+// WithHealthCheck probes all endpoints in the background, so unhealthy endpoints are tried last.
+// The checks stop when the client is closed. By default, endpoints are not probed.
+func WithHealthCheck(config HealthCheckConfig) Option {
+	return func(o *options) {
+		o.health = &config
+	}
+}
+
+// WithDiscovery locates the path oracles of the local AS using discoverer when the client is created.
+// Discovered path oracles are preferred over those given by WithBaseURL and WithEndpoints, which are used
+// if none is discovered. Once the TTL of discoverer expired, the next request repeats the discovery in the
+// background and the client switches to the newly discovered path oracles. A TTL of 0 disables the refresh.
+func WithDiscovery(discoverer *Discoverer) Option {
+	return func(o *options) {
+		o.discovery = discoverer
+	}
+}
+
+// WithTransport sets the http.RoundTripper used for all requests to the path oracle.
+// It takes precedence over WithNetwork, WithTLSConfig and WithCAFile.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = transport
+	}
+}
+
+// WithNetwork selects how requests reach the path oracle. By default, NetworkSCION is used.
+func WithNetwork(network Network) Option {
+	return func(o *options) {
+		o.network = network
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for https base URLs.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = config
+	}
+}
+
+// WithCAFile trusts the PEM encoded CA certificates in file, instead of the system roots, for https base URLs.
+func WithCAFile(file string) Option {
+	return func(o *options) {
+		o.caFile = file
+	}
+}
+
+// WithClientCertificate authenticates the client by the PEM encoded certificate and key in the given files
+// when connecting to an https base URL (mutual TLS).
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(o *options) {
+		o.certFile = certFile
+		o.keyFile = keyFile
+	}
+}
+
+// WithAuthenticator adds credentials to every request to the path oracle using auth.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(o *options) {
+		o.auth = auth
+	}
+}
+
+// WithBearerToken authenticates every request to the path oracle with a static bearer token.
+func WithBearerToken(token string) Option {
+	return WithAuthenticator(BearerToken(token))
+}
+
+// WithHMACKey signs every request to the path oracle with key, identified towards the oracle by keyID.
+func WithHMACKey(keyID string, key []byte) Option {
+	return WithAuthenticator(&HMACSigner{KeyID: keyID, Key: key})
+}
+
+// WithTimeout limits the duration of a single request to the path oracle. 0 disables the timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithLogger sets the logger of the client. By default, nothing is logged.
+func WithLogger(logger *zap.SugaredLogger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithRetryPolicy repeats requests failing due to connection errors or 5xx responses according to policy.
+// By default, requests are not retried.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithCircuitBreaker stops contacting an unreachable path oracle for a cool-down after repeated failures.
+// By default, no circuit breaker is used.
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(o *options) {
+		o.breaker = &config
+	}
+}
+
+// WithScoreCache caches fetched scores per destination and service, so connections to the same destination
+// share scores. By default, scores are not cached.
+func WithScoreCache(config ScoreCacheConfig) Option {
+	return func(o *options) {
+		o.cache = &config
+	}
+}
+
+// WithEncoding negotiates compact encodings of requests and responses with the path oracle according to config.
+// By default, only JSON is used.
+func WithEncoding(config EncodingConfig) Option {
+	return func(o *options) {
+		o.encoding = &config
+	}
+}
+
+// WithValidation checks reports against schema before submitting them. Invalid reports are not submitted, but
+// passed to quarantine, if not nil. By default, reports are not validated.
+func WithValidation(schema ReportSchema, quarantine Quarantine) Option {
+	return func(o *options) {
+		o.schema = &schema
+		o.quarantine = quarantine
+	}
+}
+
+// WithCapabilities queries the capabilities of every path oracle before the first request to it, and uses the URL
+// layout, encodings and scoring services they advertise. Requests to path oracles speaking none of the APIVersions
+// fail with an IncompatibleError. Path oracles without a capabilities endpoint are assumed to speak version 1.
+// By default, the layout of version 1 is used without querying capabilities.
+func WithCapabilities() Option {
+	return func(o *options) {
+		o.caps = true
+	}
+}
+
+// WithMetrics records all requests to path oracles in metrics. By default, nothing is recorded.
+func WithMetrics(metrics *Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
@@ -2,74 +2,518 @@ package oclient
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/clemens97/scion-path-oracle"
 	"github.com/clemens97/scion-path-oracle/server"
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
-	"github.com/netsec-ethz/scion-apps/pkg/shttp"
 	"github.com/scionproto/scion/go/lib/addr"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+	"io"
 	"net/http"
-	"os"
-)
-
-const (
-	reportingURLTemplate = "http://%s/reports/%d/%d/%s/"
-	scoringURLTemplate   = "http://%s/scorings/"
+	"oclient/cbor"
+	"sync"
 )
 
 const jsonContentType = "application/json"
 
 type OracleClient struct {
-	httpc *http.Client
+	// endpoints are ordered by priority, endpointsMutex guards replacing them, see currentEndpoints
+	endpointsMutex sync.RWMutex
+	endpoints      []*endpoint
+	// rediscovery refreshes discovered endpoints, nil without WithDiscovery
+	rediscovery *rediscovery
+	merge       MergeStrategy
+	userAgent  string
+	logger     *zap.SugaredLogger
+	retry      RetryPolicy
+	auth       Authenticator
+	cache      *scoreCache
+	encoding   *EncodingConfig
+	metrics    *Metrics
+	schema     *ReportSchema
+	quarantine Quarantine
+	// discoverCaps queries the capabilities of endpoints, see WithCapabilities
+	discoverCaps bool
+
+	stopHealth chan struct{}
+	healthDone chan struct{}
 }
 
-func NewOracleClient() OracleClient {
-	return OracleClient{httpc: &http.Client{Transport: shttp.DefaultTransport}}
+// NewOracleClient creates a client for the path oracle located at the base URL given by WithBaseURL, or for
+// several path oracles given by WithEndpoints or discovered by WithDiscovery. Without any path oracle, e.g. if the
+// base URL is empty, the client is created nonetheless and its requests fail with ErrNoOracle.
+// Unless configured otherwise by WithNetwork or WithTransport, requests are sent via HTTP over SCION.
+func NewOracleClient(opts ...Option) (*OracleClient, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	configured := o.endpoints
+	if o.baseURL != "" {
+		// the base URL is preferred over all other endpoints
+		configured = prefer([]Endpoint{{URL: o.baseURL}}, configured)
+	}
+	endpoints := configured
+	if o.discovery != nil {
+		discovered, err := o.discovery.Discover(context.Background())
+		switch {
+		case err == nil:
+			endpoints = prefer(discovered, configured)
+		case len(configured) == 0:
+			return nil, fmt.Errorf("discovering path oracle: %w", err)
+		default:
+			o.logger.Infow("path oracle discovery failed, using configured path oracles", "error", err)
+		}
+	}
+	endpoints = sortByPriority(endpoints)
+
+	var tlsConfig *tls.Config
+	if o.transport == nil {
+		var err error
+		if tlsConfig, err = o.buildTLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+	c := &OracleClient{
+		endpoints:    make([]*endpoint, len(endpoints)),
+		merge:        o.merge,
+		userAgent:    o.userAgent,
+		logger:       o.logger,
+		retry:        o.retry,
+		auth:         o.auth,
+		encoding:     o.encoding,
+		metrics:      o.metrics,
+		schema:       o.schema,
+		quarantine:   o.quarantine,
+		discoverCaps: o.caps,
+	}
+	build := func(e Endpoint) (*endpoint, error) {
+		return newEndpoint(e, &o, tlsConfig)
+	}
+	for i, e := range endpoints {
+		ep, err := build(e)
+		if err != nil {
+			return nil, err
+		}
+		c.endpoints[i] = ep
+	}
+	if o.discovery != nil && o.discovery.config.TTL > 0 {
+		c.rediscovery = &rediscovery{
+			discoverer:  o.discovery,
+			configured:  configured,
+			newEndpoint: build,
+			due:         o.discovery.now().Add(o.discovery.config.TTL),
+		}
+	}
+	if o.cache != nil {
+		c.cache = newScoreCache(*o.cache, o.logger)
+	}
+	if o.health != nil && o.health.Interval > 0 {
+		c.stopHealth = make(chan struct{})
+		c.healthDone = make(chan struct{})
+		go c.checkHealth(*o.health)
+	}
+	return c, nil
 }
 
-func (c *OracleClient) FetchScores(query server.ScoringQuery) (server.ScoringResponse, error) {
-	body, err := json.Marshal(query)
+func newEndpoint(e Endpoint, o *options, tlsConfig *tls.Config) (*endpoint, error) {
+	baseURL, err := parseBaseURL(e.URL)
 	if err != nil {
 		return nil, err
 	}
-	res, err := c.httpc.Post(scoringURL(), jsonContentType, bytes.NewReader(body))
-	if err != nil {
-		return nil, err
+	transport := o.transport
+	if transport == nil {
+		transport, err = newTransport(o.network, baseURL, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ep := &endpoint{
+		Endpoint: e,
+		baseURL:  baseURL,
+		httpc:    &http.Client{Transport: transport, Timeout: o.timeout},
+		streamc:  &http.Client{Transport: transport},
+		healthy:  true,
+	}
+	if o.breaker != nil {
+		ep.breaker = newCircuitBreaker(*o.breaker)
+	}
+	return ep, nil
+}
+
+func minPriority(endpoints []Endpoint) int {
+	min := endpoints[0].Priority
+	for _, e := range endpoints[1:] {
+		if e.Priority < min {
+			min = e.Priority
+		}
+	}
+	return min
+}
+
+// Close stops the health checks of the endpoints, if any.
+func (c *OracleClient) Close() {
+	if c.stopHealth == nil {
+		return
 	}
-	defer res.Body.Close()
+	select {
+	case <-c.stopHealth:
+	default:
+		close(c.stopHealth)
+	}
+	<-c.healthDone
+}
+
+// BreakerState returns the state of the least restrictive circuit breaker of all endpoints,
+// always BreakerClosed if none is configured and BreakerOpen if there is no endpoint.
+func (c *OracleClient) BreakerState() BreakerState {
+	state := BreakerOpen
+	for _, ep := range c.currentEndpoints() {
+		switch ep.breaker.currentState() {
+		case BreakerClosed:
+			return BreakerClosed
+		case BreakerHalfOpen:
+			state = BreakerHalfOpen
+		}
+	}
+	return state
+}
+
+// Degraded reports whether all path oracles are considered unreachable and requests are currently rejected.
+func (c *OracleClient) Degraded() bool {
+	return c.BreakerState() == BreakerOpen
+}
+
+// BaseURL returns the location of the preferred path oracle this client talks to, empty if there is none.
+func (c *OracleClient) BaseURL() string {
+	eps := c.currentEndpoints()
+	if len(eps) == 0 {
+		return ""
+	}
+	return eps[0].baseURL.String()
+}
+
+// FetchScores is like FetchScoresContext using the background context.
+func (c *OracleClient) FetchScores(query server.ScoringQuery) (server.ScoringResponse, error) {
+	return c.FetchScoresContext(context.Background(), query)
+}
 
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, errors.New("path oracle returned non 2xx status code:" + res.Status)
+// FetchScoresContext queries the path oracle for the scores of all paths to the destinations in query.
+// The request is aborted as soon as ctx is done. If a score cache is configured, cached scores are returned
+// without contacting the path oracle.
+func (c *OracleClient) FetchScoresContext(ctx context.Context, query server.ScoringQuery) (res server.ScoringResponse, err error) {
+	ctx, span := startSpan(ctx, "OracleClient.FetchScores", queryAttributes(query)...)
+	defer func() {
+		traceScores(span, res)
+		EndSpan(span, err)
+	}()
+
+	if c.cache != nil {
+		return c.cache.fetch(ctx, query, c.fetchScores)
+	}
+	return c.fetchScores(ctx, query)
+}
+
+func (c *OracleClient) fetchScores(ctx context.Context, query server.ScoringQuery) (server.ScoringResponse, error) {
+	eps := c.currentEndpoints()
+	if c.merge == MergeFirstWins || len(eps) == 1 {
+		return c.fetchFirstScores(ctx, query)
 	}
 
-	var scoringRes server.ScoringResponse
-	if err := json.NewDecoder(res.Body).Decode(&scoringRes); err != nil {
+	results := make([]server.ScoringResponse, len(eps))
+	errs := fanOut(eps, func(i int, ep *endpoint) error {
+		return c.queryScores(ctx, ep, query, &results[i])
+	})
+	var responses []weightedResponse
+	for i, err := range errs {
+		if err == nil {
+			responses = append(responses, weightedResponse{res: results[i], confidence: eps[i].Confidence})
+		}
+	}
+	if err := c.anySucceeded(eps, errs, "error fetching scores from path oracle"); err != nil {
 		return nil, err
 	}
-	return scoringRes, nil
+	return mergeScores(c.merge, responses), nil
 }
 
+// fetchFirstScores fetches scores from the preferred endpoint, failing over to the next one as long as
+// endpoints are unavailable or offer none of the queried services.
+func (c *OracleClient) fetchFirstScores(ctx context.Context, query server.ScoringQuery) (server.ScoringResponse, error) {
+	err := ErrNoOracle
+	eps := c.candidates()
+	for _, ep := range eps {
+		var scoringRes server.ScoringResponse
+		if err = c.queryScores(ctx, ep, query, &scoringRes); err == nil {
+			return scoringRes, nil
+		}
+		if !errors.Is(err, ErrOracleUnavailable) && !errors.Is(err, ErrServiceUnsupported) || ctx.Err() != nil {
+			return nil, err
+		}
+		if len(eps) > 1 {
+			c.logger.Infow("path oracle failed, failing over", "endpoint", ep.baseURL, "error", err)
+		}
+	}
+	return nil, err
+}
+
+// queryScores fetches the scores of the services in query the endpoint offers.
+func (c *OracleClient) queryScores(ctx context.Context, ep *endpoint, query server.ScoringQuery,
+	res *server.ScoringResponse) error {
+	caps, err := c.capabilities(ctx, ep)
+	if err != nil {
+		return err
+	}
+	if query, err = c.supportedQuery(ep, caps, query); err != nil {
+		return err
+	}
+	return c.do(ctx, ep, opScores, ep.url(caps.Paths.Scorings), query, ErrBadRequest, res)
+}
+
+// reportStats submits a single report to the endpoint.
+func (c *OracleClient) reportStats(ctx context.Context, ep *endpoint, report oracle.Report) error {
+	paths, err := c.paths(ctx, ep)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, ep, opReport, ep.reportingURL(paths, report.DstIA, report.PathFp), report, ErrReportRejected, nil)
+}
+
+// ReportStats is like ReportStatsContext using the background context.
 func (c *OracleClient) ReportStats(report oracle.Report) error {
-	body, err := json.Marshal(report)
+	return c.ReportStatsContext(context.Background(), report)
+}
+
+// ReportStatsContext submits the stats of a connection using the path report.PathFp to all path oracles.
+// It succeeds if any path oracle accepted the report. The request is aborted as soon as ctx is done.
+// Reports violating the schema configured by WithValidation fail with an InvalidReportError.
+func (c *OracleClient) ReportStatsContext(ctx context.Context, report oracle.Report) (err error) {
+	ctx, span := startSpan(ctx, "OracleClient.ReportStats", reportAttributes(report)...)
+	defer func() { EndSpan(span, err) }()
+	if err = c.validate(report); err != nil {
+		return err
+	}
+
+	eps := c.currentEndpoints()
+	errs := fanOut(eps, func(i int, ep *endpoint) error {
+		return c.reportStats(ctx, ep, report)
+	})
+	return c.anySucceeded(eps, errs, "error reporting stats to path oracle")
+}
+
+// batchReport is the representation of a report within a batch, carrying the path parameters in the body.
+type batchReport struct {
+	DstIA  addr.IA                `json:"dst_ia"`
+	PathFp oracle.PathFingerprint `json:"path_fp"`
+	oracle.Report
+}
+
+// MarshalCBOR encodes the IA as text, like in JSON.
+func (b batchReport) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(struct {
+		DstIA  string                 `json:"dst_ia"`
+		PathFp oracle.PathFingerprint `json:"path_fp"`
+		oracle.Report
+	}{b.DstIA.String(), b.PathFp, b.Report})
+}
+
+// ReportStatsBatchContext submits several reports in a single request to every path oracle. If no path oracle
+// offers the batch endpoint, ErrBatchUnsupported is returned and the reports have to be submitted one by one.
+// Path oracles without the batch endpoint receive the reports one by one if others offer it.
+// Reports violating the schema configured by WithValidation are left out of the batch.
+func (c *OracleClient) ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) (err error) {
+	ctx, span := startSpan(ctx, "OracleClient.ReportStatsBatch", attribute.Int("report.count", len(reports)))
+	defer func() { EndSpan(span, err) }()
+
+	valid := make([]oracle.Report, 0, len(reports))
+	for _, r := range reports {
+		if err = c.validate(r); err == nil {
+			valid = append(valid, r)
+		}
+	}
+	if len(valid) == 0 && len(reports) > 0 {
+		return err
+	}
+	reports = valid
+	batch := make([]batchReport, len(reports))
+	for i, r := range reports {
+		batch[i] = batchReport{DstIA: r.DstIA, PathFp: r.PathFp, Report: r}
+	}
+
+	eps := c.currentEndpoints()
+	errs := fanOut(eps, func(i int, ep *endpoint) error {
+		paths, err := c.paths(ctx, ep)
+		if err != nil {
+			return err
+		}
+		err = c.do(ctx, ep, opBatch, ep.url(paths.Batch), batch, ErrReportRejected, nil)
+		var resErr *ResponseError
+		if errors.As(err, &resErr) &&
+			(resErr.StatusCode == http.StatusNotFound || resErr.StatusCode == http.StatusMethodNotAllowed) {
+			return ErrBatchUnsupported
+		}
+		return err
+	})
+	unsupported := 0
+	for _, err := range errs {
+		if errors.Is(err, ErrBatchUnsupported) {
+			unsupported++
+		}
+	}
+	if unsupported == len(errs) {
+		return ErrBatchUnsupported
+	}
+	for i, err := range errs {
+		if !errors.Is(err, ErrBatchUnsupported) {
+			continue
+		}
+		ep := eps[i]
+		errs[i] = nil
+		for _, r := range reports {
+			if err := c.reportStats(ctx, ep, r); err != nil {
+				errs[i] = err
+			}
+		}
+	}
+	return c.anySucceeded(eps, errs, "error reporting stats to path oracle")
+}
+
+// do posts in, encoded as JSON unless a compact encoding was negotiated, and decodes the response into out,
+// if out is not nil. Non 2xx responses are returned as ResponseError, 4xx responses other than 400 Bad Request
+// and 401 Unauthorized wrapping rejected.
+func (c *OracleClient) do(ctx context.Context, ep *endpoint, operation, url string, in interface{},
+	rejected error, out interface{}) error {
+	p, err := c.encode(ep, in)
+	if err != nil {
+		return err
+	}
+	res, err := c.request(ctx, ep, http.MethodPost, operation, url, p)
 	if err != nil {
 		return err
 	}
-	_, err = c.httpc.Post(reportingURL(report.DstIA, report.PathFp), jsonContentType, bytes.NewReader(body))
-	return err
+	if res.StatusCode == http.StatusUnsupportedMediaType && p.compact() {
+		// the path oracle stopped supporting the negotiated encoding, fall back to JSON
+		c.logger.Infow("path oracle rejected compact encoding, falling back to JSON", "endpoint", ep.baseURL,
+			"content_type", p.contentType, "content_encoding", p.contentEncoding)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		ep.setNegotiated(false, false)
+		if p, err = c.encode(ep, in); err != nil {
+			return err
+		}
+		if res, err = c.request(ctx, ep, http.MethodPost, operation, url, p); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		// drain the body to allow reusing the connection
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}()
+
+	if err := checkResponse(res, rejected); err != nil {
+		return err
+	}
+	c.negotiate(ep, res)
+	if out == nil {
+		return nil
+	}
+	if err := decode(res, out); err != nil {
+		return fmt.Errorf("could not decode path oracle response: %w", err)
+	}
+	return nil
 }
 
-func reportingURL(dst addr.IA, fp oracle.PathFingerprint) string {
-	return fmt.Sprintf(reportingURLTemplate, pathOracleLocation(), dst.I, dst.A, fp)
+// request sends p, if not nil, to the endpoint, retrying according to the retry policy while the circuit breaker
+// allows it. On 5xx responses of the final attempt the response is returned to the caller.
+func (c *OracleClient) request(ctx context.Context, ep *endpoint, method, operation, url string,
+	p *payload) (*http.Response, error) {
+	bodySize := 0
+	if p != nil {
+		bodySize = len(p.body)
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, url, p)
+		if err != nil {
+			return nil, err
+		}
+		if !ep.breaker.allow() {
+			c.metrics.circuitOpen(ep, operation)
+			return nil, ErrCircuitOpen
+		}
+		c.logger.Debugw("sending request to path oracle", "url", url)
+		res, err := c.send(ep, operation, ep.httpc, req, bodySize)
+		if err != nil {
+			err = &ConnectionError{Err: err}
+		}
+		if !isRetryable(ctx, res, err) {
+			if err != nil {
+				ep.breaker.release()
+			} else {
+				ep.breaker.success()
+			}
+			return res, err
+		}
+
+		ep.breaker.failure()
+		if attempt+1 >= c.retry.MaxAttempts {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		wait := c.retry.backoff(attempt)
+		c.logger.Debugw("retrying request to path oracle", "url", url, "attempt", attempt+1, "error", err,
+			"backoff", wait)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, &ConnectionError{Err: err}
+		}
+	}
+}
+
+// newRequest creates an authenticated request sending p, if not nil, to url.
+func (c *OracleClient) newRequest(ctx context.Context, method, url string, p *payload) (*http.Request, error) {
+	var body []byte
+	if p != nil {
+		body = p.body
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p != nil {
+		req.Header.Set("Content-Type", p.contentType)
+		if p.contentEncoding != "" {
+			req.Header.Set("Content-Encoding", p.contentEncoding)
+		}
+	}
+	if c.encoding != nil && c.encoding.CBOR {
+		req.Header.Set("Accept", cbor.ContentType+", "+jsonContentType+";q=0.9")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req, body); err != nil {
+			return nil, fmt.Errorf("authenticating request to path oracle: %w", err)
+		}
+	}
+	return req, nil
 }
 
-func scoringURL() string {
-	return fmt.Sprintf(scoringURLTemplate, pathOracleLocation())
+// StatsReporter submits connection stats to a path oracle, either directly or through intermediate stages
+// like a spool or a queue.
+type StatsReporter interface {
+	ReportStatsContext(ctx context.Context, report oracle.Report) error
 }
 
-func pathOracleLocation() string {
-	return os.Getenv("PATH_ORACLE")
+// BatchStatsReporter is a StatsReporter able to submit several reports at once.
+type BatchStatsReporter interface {
+	StatsReporter
+	ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) error
 }
 
 // PathPublisher publish path updates to a PathSubscriber.
@@ -0,0 +1,200 @@
+package oclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewOracleClientRejectsInvalidBaseURL(t *testing.T) {
+	_, err := NewOracleClient(WithBaseURL("oracle.local:8080"))
+	assert.Error(t, err)
+}
+
+func TestUnconfiguredClientFailsOnUse(t *testing.T) {
+	c, err := NewOracleClient(WithBaseURL(""))
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Empty(t, c.BaseURL())
+	assert.True(t, c.Degraded())
+
+	ctx := context.Background()
+	_, err = c.Capabilities(ctx)
+	assert.ErrorIs(t, err, ErrNoOracle)
+	_, err = c.FetchScoresContext(ctx, server.ScoringQuery{})
+	assert.ErrorIs(t, err, ErrNoOracle)
+	_, err = c.Subscribe(ctx, nil, DefaultSubscriptionConfig)
+	assert.ErrorIs(t, err, ErrNoOracle)
+	assert.ErrorIs(t, c.ReportStatsContext(ctx, oracle.Report{}), ErrNoOracle)
+	assert.ErrorIs(t, c.ReportStatsBatchContext(ctx, []oracle.Report{{}}), ErrNoOracle)
+}
+
+func TestFetchScoresWithPathPrefix(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/scorings/", r.URL.Path)
+		assert.Equal(t, "test-agent", r.UserAgent())
+
+		var q server.ScoringQuery
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+		assert.Equal(t, []services.ServiceName{"throughput"}, q.Queries[dst.String()])
+
+		json.NewEncoder(w).Encode(server.ScoringResponse{
+			dst: {{Fingerprint: "a", Scores: map[string]float64{"throughput": 42}}},
+		})
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL+"/api/"), WithTransport(http.DefaultTransport),
+		WithUserAgent("test-agent"))
+	assert.NoError(t, err)
+
+	res, err := c.FetchScores(server.ScoringQuery{Queries: map[string][]services.ServiceName{dst.String(): {"throughput"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, 42., res[dst][0].Scores["throughput"])
+}
+
+func TestReportStatsURL(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	assert.NoError(t, err)
+
+	err = c.ReportStats(oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "1 2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/reports/1/13/1 2/", path)
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.ReportStats(oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "a"}))
+	assert.Equal(t, 3, attempts)
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+	assert.NoError(t, err)
+
+	_, err = c.FetchScores(server.ScoringQuery{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestCircuitBreakerRejectsRequests(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, CoolDown: time.Hour}))
+	assert.NoError(t, err)
+
+	_, err = c.FetchScores(server.ScoringQuery{})
+	assert.Error(t, err)
+	assert.True(t, c.Degraded())
+
+	_, err = c.FetchScores(server.ScoringQuery{})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestReportStatsTypedErrors(t *testing.T) {
+	status := http.StatusForbidden
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown path", status)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	assert.NoError(t, err)
+	report := oracle.Report{DstIA: addr.IA{I: 1, A: 13}, PathFp: "a"}
+
+	err = c.ReportStats(report)
+	assert.ErrorIs(t, err, ErrReportRejected)
+	var resErr *ResponseError
+	if assert.True(t, errors.As(err, &resErr)) {
+		assert.Equal(t, http.StatusForbidden, resErr.StatusCode)
+		assert.Equal(t, "unknown path", resErr.Body)
+	}
+
+	status = http.StatusBadRequest
+	assert.ErrorIs(t, c.ReportStats(report), ErrBadRequest)
+
+	status = http.StatusBadGateway
+	assert.ErrorIs(t, c.ReportStats(report), ErrOracleUnavailable)
+
+	srv.Close()
+	err = c.ReportStats(report)
+	assert.ErrorIs(t, err, ErrOracleUnavailable)
+	assert.True(t, errors.As(err, new(*ConnectionError)))
+}
+
+func TestReportStatsBatch(t *testing.T) {
+	var batch []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/reports/" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	assert.NoError(t, err)
+	reports := []oracle.Report{
+		{DstIA: addr.IA{I: 1, A: 13}, PathFp: "a", Properties: oracle.MonitoredProperties{"throughput": 1.}},
+		{DstIA: addr.IA{I: 1, A: 14}, PathFp: "b"},
+	}
+	assert.NoError(t, c.ReportStatsBatchContext(context.Background(), reports))
+	if assert.Len(t, batch, 2) {
+		assert.Equal(t, "1-13", batch[0]["dst_ia"])
+		assert.Equal(t, "a", batch[0]["path_fp"])
+		assert.Equal(t, map[string]interface{}{"throughput": 1.}, batch[0]["stats"])
+	}
+
+	c, err = NewOracleClient(WithBaseURL(srv.URL+"/v0"), WithTransport(http.DefaultTransport))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, c.ReportStatsBatchContext(context.Background(), reports), ErrBatchUnsupported)
+}
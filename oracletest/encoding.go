@@ -0,0 +1,127 @@
+package oracletest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"oclient/cbor"
+	"strings"
+)
+
+const (
+	jsonContentType = "application/json"
+	gzipEncoding    = "gzip"
+	// maxBodySize limits the size of decoded request bodies.
+	maxBodySize = 4 << 20
+)
+
+// JSONOnly makes the oracle behave like a path oracle without support for compact encodings: it does not
+// advertise them and answers requests using them with 415 Unsupported Media Type.
+func (o *Oracle) JSONOnly(jsonOnly bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.jsonOnly = jsonOnly
+}
+
+// Encodings returns the content type and encoding of every decoded request body, e.g. "application/cbor+gzip".
+func (o *Oracle) Encodings() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]string(nil), o.encodings...)
+}
+
+func (o *Oracle) isJSONOnly() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.jsonOnly
+}
+
+// advertise announces the accepted request encodings.
+func (o *Oracle) advertise(w http.ResponseWriter) {
+	if o.isJSONOnly() {
+		return
+	}
+	w.Header().Set("Accept-Post", jsonContentType+", "+cbor.ContentType)
+	w.Header().Set("Accept-Encoding", gzipEncoding)
+}
+
+// decodeBody decodes the request body into v according to its content type and encoding. On failure, an error
+// is sent to the client and false returned.
+func (o *Oracle) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	mediaType := jsonContentType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, _ = mime.ParseMediaType(ct)
+	}
+	encoding := r.Header.Get("Content-Encoding")
+	jsonOnly := o.isJSONOnly()
+	if (mediaType != jsonContentType && (jsonOnly || mediaType != cbor.ContentType)) ||
+		(encoding != "" && (jsonOnly || encoding != gzipEncoding)) {
+		http.Error(w, fmt.Sprintf("unsupported content type %q or encoding %q", mediaType, encoding),
+			http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	body := io.Reader(r.Body)
+	if encoding == gzipEncoding {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		defer gz.Close()
+		body = gz
+	}
+	raw, err := io.ReadAll(io.LimitReader(body, maxBodySize))
+	if err == nil {
+		if mediaType == cbor.ContentType {
+			err = cbor.Unmarshal(raw, v)
+		} else {
+			err = json.Unmarshal(raw, v)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	if encoding != "" {
+		mediaType += "+" + encoding
+	}
+	o.mutex.Lock()
+	o.encodings = append(o.encodings, mediaType)
+	o.mutex.Unlock()
+	return true
+}
+
+// writeBody sends v encoded as CBOR if the client accepts it and the oracle supports it, as JSON otherwise.
+func (o *Oracle) writeBody(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if !o.isJSONOnly() && acceptsCBOR(r) {
+		if data, err := cbor.Marshal(v); err == nil {
+			w.Header().Set("Content-Type", cbor.ContentType)
+			w.Write(data)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", jsonContentType)
+	json.NewEncoder(w).Encode(v)
+}
+
+// acceptsCBOR reports whether the Accept header of r lists CBOR before JSON.
+func acceptsCBOR(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		switch mediaType {
+		case cbor.ContentType:
+			return true
+		case jsonContentType:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,410 @@
+// Package oracletest provides an in-memory path oracle speaking the REST API of the scion-path-oracle,
+// to be used in tests and for local development.
+package oracletest
+
+import (
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"oclient/cbor"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	scoringPath      = "/scorings/"
+	subscriptionPath = "/scorings/subscribe/"
+	reportingPath    = "/reports/"
+	servicesPath     = "/services/"
+	capabilitiesPath = "/capabilities/"
+)
+
+// Oracle is an http.Handler implementing the scoring and reporting endpoints of a path oracle.
+// Scores are programmed by the test, received reports are recorded.
+type Oracle struct {
+	mutex  sync.Mutex
+	scores map[addr.IA]map[oracle.PathFingerprint]map[string]float64
+	// reports in the order they were received
+	reports []oracle.Report
+
+	latency           time.Duration
+	status            int
+	failNext          int
+	failNextStatus    int
+	rejectUnknownPath bool
+	requests          int
+	jsonOnly          bool
+	encodings         []string
+	capabilities      interface{}
+
+	subscribers map[*subscriber]struct{}
+	// streamsStopped rejects new subscriptions once the server shuts down
+	streamsStopped bool
+
+	// Validate rejects reports with 403 Forbidden if it returns an error, if set.
+	Validate func(report oracle.Report) error
+	// OnReport is called for every accepted report, if set.
+	OnReport func(report oracle.Report)
+}
+
+// New returns an Oracle without any scores.
+func New() *Oracle {
+	return &Oracle{
+		scores:      make(map[addr.IA]map[oracle.PathFingerprint]map[string]float64),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Server is an Oracle served by an httptest.Server.
+type Server struct {
+	*Oracle
+	*httptest.Server
+}
+
+// NewServer starts a new Oracle listening on a local TCP port. The caller must Close it.
+func NewServer() *Server {
+	o := New()
+	return &Server{Oracle: o, Server: httptest.NewServer(o)}
+}
+
+// Close ends all subscriptions and shuts down the server.
+func (s *Server) Close() {
+	s.Oracle.stopStreams()
+	s.Server.Close()
+}
+
+// SetScore sets the score of service for the path fp to dst.
+func (o *Oracle) SetScore(dst addr.IA, fp oracle.PathFingerprint, service string, score float64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.scores[dst] == nil {
+		o.scores[dst] = make(map[oracle.PathFingerprint]map[string]float64)
+	}
+	if o.scores[dst][fp] == nil {
+		o.scores[dst][fp] = make(map[string]float64)
+	}
+	o.scores[dst][fp][service] = score
+	o.notify(dst)
+}
+
+// Score returns the score of service for the path fp to dst, if any.
+func (o *Oracle) Score(dst addr.IA, fp oracle.PathFingerprint, service string) (float64, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	score, ok := o.scores[dst][fp][service]
+	return score, ok
+}
+
+// Scores returns a copy of all scores of paths to dst.
+func (o *Oracle) Scores(dst addr.IA) map[oracle.PathFingerprint]map[string]float64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.copyScores(dst)
+}
+
+// AllScores returns a copy of all scores of paths to all destinations.
+func (o *Oracle) AllScores() map[addr.IA]map[oracle.PathFingerprint]map[string]float64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	res := make(map[addr.IA]map[oracle.PathFingerprint]map[string]float64, len(o.scores))
+	for dst := range o.scores {
+		res[dst] = o.copyScores(dst)
+	}
+	return res
+}
+
+func (o *Oracle) copyScores(dst addr.IA) map[oracle.PathFingerprint]map[string]float64 {
+	res := make(map[oracle.PathFingerprint]map[string]float64, len(o.scores[dst]))
+	for fp, scores := range o.scores[dst] {
+		res[fp] = make(map[string]float64, len(scores))
+		for service, score := range scores {
+			res[fp][service] = score
+		}
+	}
+	return res
+}
+
+// Reports returns all reports received so far.
+func (o *Oracle) Reports() []oracle.Report {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]oracle.Report(nil), o.reports...)
+}
+
+// Requests returns the number of requests received so far, including failed ones.
+func (o *Oracle) Requests() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.requests
+}
+
+// SetLatency delays every response by latency.
+func (o *Oracle) SetLatency(latency time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.latency = latency
+}
+
+// SetStatus answers every request with status, 0 restores the regular behaviour.
+func (o *Oracle) SetStatus(status int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.status = status
+}
+
+// FailNext answers the next n requests with status.
+func (o *Oracle) FailNext(n int, status int) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.failNext = n
+	o.failNextStatus = status
+}
+
+// SetCapabilities serves capabilities at the capabilities endpoint. Like the path oracle, the Oracle has no
+// capabilities endpoint as long as they are nil.
+func (o *Oracle) SetCapabilities(capabilities interface{}) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.capabilities = capabilities
+}
+
+// RejectUnknownPaths answers reports for paths without any score with 403 Forbidden, as the
+// path oracle does for paths it does not know. Batches are only answered with 403 Forbidden if all of their
+// reports are rejected, see handleBatchReport.
+func (o *Oracle) RejectUnknownPaths(reject bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.rejectUnknownPath = reject
+}
+
+func (o *Oracle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	status, latency := o.onRequest()
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	o.advertise(w)
+	if r.Method == http.MethodGet && r.URL.Path == servicesPath {
+		o.handleServices(w, r)
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Path == capabilitiesPath {
+		o.handleCapabilities(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case r.URL.Path == subscriptionPath:
+		o.handleSubscription(w, r)
+	case r.URL.Path == scoringPath:
+		o.handleScoring(w, r)
+	case r.URL.Path == reportingPath:
+		o.handleBatchReport(w, r)
+	case strings.HasPrefix(r.URL.Path, reportingPath):
+		o.handleReport(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleServices lists all services with scores, like the path oracle lists its scoring services.
+func (o *Oracle) handleServices(w http.ResponseWriter, r *http.Request) {
+	o.mutex.Lock()
+	seen := make(map[string]bool)
+	for _, paths := range o.scores {
+		for _, scores := range paths {
+			for service := range scores {
+				seen[service] = true
+			}
+		}
+	}
+	o.mutex.Unlock()
+
+	names := make([]string, 0, len(seen))
+	for service := range seen {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+	o.writeBody(w, r, names)
+}
+
+func (o *Oracle) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	o.mutex.Lock()
+	capabilities := o.capabilities
+	o.mutex.Unlock()
+	if capabilities == nil {
+		http.NotFound(w, r)
+		return
+	}
+	o.writeBody(w, r, capabilities)
+}
+
+// onRequest counts the request and returns the injected status code (if any) and latency.
+func (o *Oracle) onRequest() (status int, latency time.Duration) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.requests++
+	if o.failNext > 0 {
+		o.failNext--
+		return o.failNextStatus, o.latency
+	}
+	return o.status, o.latency
+}
+
+func (o *Oracle) handleScoring(w http.ResponseWriter, r *http.Request) {
+	var q server.ScoringQuery
+	if !o.decodeBody(w, r, &q) {
+		return
+	}
+	res, err := o.scoringResponse(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	o.writeBody(w, r, res)
+}
+
+// scoringResponse answers q with the current scores.
+func (o *Oracle) scoringResponse(q server.ScoringQuery) (server.ScoringResponse, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	res := make(server.ScoringResponse, len(q.Queries))
+	for qDst, qServices := range q.Queries {
+		dst, err := addr.IAFromString(qDst)
+		if err != nil {
+			return nil, err
+		}
+		for fp, scores := range o.scores[dst] {
+			fpScores := server.FingerprintScores{Fingerprint: fp, Scores: make(map[string]float64)}
+			for _, service := range qServices {
+				if score, ok := scores[string(service)]; ok {
+					fpScores.Scores[string(service)] = score
+				}
+			}
+			if len(fpScores.Scores) > 0 {
+				res[dst] = append(res[dst], fpScores)
+			}
+		}
+	}
+	return res, nil
+}
+
+func (o *Oracle) handleReport(w http.ResponseWriter, r *http.Request) {
+	// /reports/{isd}/{as}/{fp}/
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, reportingPath), "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+	isd, errI := strconv.ParseUint(parts[0], 10, 16)
+	as, errA := strconv.ParseUint(parts[1], 10, 64)
+	fp, errF := url.PathUnescape(parts[2])
+	if errI != nil || errA != nil || errF != nil {
+		http.Error(w, "invalid path parameters", http.StatusBadRequest)
+		return
+	}
+
+	var report oracle.Report
+	if !o.decodeBody(w, r, &report) {
+		return
+	}
+	report.DstIA = addr.IA{I: addr.ISD(isd), A: addr.AS(as)}
+	report.PathFp = oracle.PathFingerprint(fp)
+
+	if !o.accept(report) {
+		http.Error(w, "unknown path or invalid report", http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// batchReport mirrors the representation of a report in a batch as sent by the oclient.
+type batchReport struct {
+	DstIA  addr.IA                `json:"dst_ia"`
+	PathFp oracle.PathFingerprint `json:"path_fp"`
+	oracle.Report
+}
+
+// UnmarshalCBOR decodes the IA from its text representation, like in JSON.
+func (b *batchReport) UnmarshalCBOR(data []byte) error {
+	var encoded struct {
+		DstIA  string                 `json:"dst_ia"`
+		PathFp oracle.PathFingerprint `json:"path_fp"`
+		oracle.Report
+	}
+	if err := cbor.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	dst, err := addr.IAFromString(encoded.DstIA)
+	if err != nil {
+		return err
+	}
+	*b = batchReport{DstIA: dst, PathFp: encoded.PathFp, Report: encoded.Report}
+	return nil
+}
+
+// handleBatchReport records the accepted reports of a batch. The batch is answered with 201 Created if any of its
+// reports was accepted, the rejection of the others is silent. Tests check which reports were recorded by Reports.
+func (o *Oracle) handleBatchReport(w http.ResponseWriter, r *http.Request) {
+	var batch []batchReport
+	if !o.decodeBody(w, r, &batch) {
+		return
+	}
+	rejected := 0
+	for _, b := range batch {
+		report := b.Report
+		report.DstIA, report.PathFp = b.DstIA, b.PathFp
+		if !o.accept(report) {
+			rejected++
+		}
+	}
+	if rejected == len(batch) && rejected > 0 {
+		http.Error(w, "unknown paths or invalid reports", http.StatusForbidden)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// accept records report unless it is rejected because its path is unknown or it is invalid.
+func (o *Oracle) accept(report oracle.Report) bool {
+	o.mutex.Lock()
+	if o.rejectUnknownPath && o.scores[report.DstIA][report.PathFp] == nil {
+		o.mutex.Unlock()
+		return false
+	}
+	validate := o.Validate
+	o.mutex.Unlock()
+	if validate != nil && validate(report) != nil {
+		return false
+	}
+
+	o.mutex.Lock()
+	o.reports = append(o.reports, report)
+	onReport := o.OnReport
+	o.mutex.Unlock()
+
+	if onReport != nil {
+		onReport(report)
+	}
+	return true
+}
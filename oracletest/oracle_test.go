@@ -0,0 +1,211 @@
+package oracletest
+
+import (
+	"context"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"oclient"
+	"testing"
+	"time"
+)
+
+var (
+	dst             = addr.IA{I: 1, A: 13}
+	throughputQuery = server.ScoringQuery{Queries: map[string][]services.ServiceName{dst.String(): {"throughput"}}}
+)
+
+func newClient(t *testing.T, s *Server, opts ...oclient.Option) *oclient.OracleClient {
+	opts = append([]oclient.Option{
+		oclient.WithBaseURL(s.URL),
+		oclient.WithTransport(http.DefaultTransport),
+		oclient.WithRetryPolicy(oclient.RetryPolicy{MaxAttempts: 1}),
+	}, opts...)
+	c, err := oclient.NewOracleClient(opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestScoresAreServedPerService(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetScore(dst, "fp1", "throughput", 10)
+	s.SetScore(dst, "fp2", "throughput", 20)
+	s.SetScore(dst, "fp2", "latency", 5)
+	s.SetScore(addr.IA{I: 2, A: 1}, "fp3", "throughput", 30)
+
+	res, err := newClient(t, s).FetchScoresContext(context.Background(), throughputQuery)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	scores := map[oracle.PathFingerprint]map[string]float64{}
+	for _, fs := range res[dst] {
+		scores[fs.Fingerprint] = fs.Scores
+	}
+	assert.Equal(t, map[oracle.PathFingerprint]map[string]float64{
+		"fp1": {"throughput": 10},
+		"fp2": {"throughput": 20},
+	}, scores)
+}
+
+func TestReportsAreRecorded(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	var hooked []oracle.Report
+	s.OnReport = func(report oracle.Report) { hooked = append(hooked, report) }
+
+	report := oracle.Report{PathFp: "a b", DstIA: dst}
+	report.Properties = map[string]interface{}{"throughput": 42.0}
+	require.NoError(t, newClient(t, s).ReportStatsContext(context.Background(), report))
+
+	reports := s.Reports()
+	require.Len(t, reports, 1)
+	assert.Equal(t, dst, reports[0].DstIA)
+	assert.Equal(t, oracle.PathFingerprint("a b"), reports[0].PathFp)
+	assert.Equal(t, 42.0, reports[0].Properties["throughput"])
+	assert.Equal(t, reports, hooked)
+}
+
+func TestBatchReportsAreRecorded(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	err := newClient(t, s).ReportStatsBatchContext(context.Background(), []oracle.Report{
+		{PathFp: "fp1", DstIA: dst},
+		{PathFp: "fp2", DstIA: dst},
+	})
+	require.NoError(t, err)
+	require.Len(t, s.Reports(), 2)
+	assert.Equal(t, oracle.PathFingerprint("fp2"), s.Reports()[1].PathFp)
+}
+
+func TestBatchReportsArePartiallyRejected(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RejectUnknownPaths(true)
+	s.SetScore(dst, "known", "throughput", 1)
+	c := newClient(t, s)
+
+	// the batch succeeds although one of its reports is rejected
+	err := c.ReportStatsBatchContext(context.Background(), []oracle.Report{
+		{PathFp: "unknown", DstIA: dst},
+		{PathFp: "known", DstIA: dst},
+	})
+	require.NoError(t, err)
+	require.Len(t, s.Reports(), 1)
+	assert.Equal(t, oracle.PathFingerprint("known"), s.Reports()[0].PathFp)
+
+	err = c.ReportStatsBatchContext(context.Background(), []oracle.Report{{PathFp: "unknown", DstIA: dst}})
+	assert.ErrorIs(t, err, oclient.ErrReportRejected)
+	assert.Len(t, s.Reports(), 1)
+}
+
+func TestRejectUnknownPaths(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.RejectUnknownPaths(true)
+	s.SetScore(dst, "known", "throughput", 1)
+	c := newClient(t, s)
+
+	err := c.ReportStatsContext(context.Background(), oracle.Report{PathFp: "unknown", DstIA: dst})
+	assert.True(t, errors.Is(err, oclient.ErrReportRejected))
+	assert.NoError(t, c.ReportStatsContext(context.Background(), oracle.Report{PathFp: "known", DstIA: dst}))
+	assert.Len(t, s.Reports(), 1)
+}
+
+func TestInjectedFailures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(t, s, oclient.WithRetryPolicy(oclient.RetryPolicy{MaxAttempts: 3}))
+
+	s.FailNext(2, http.StatusServiceUnavailable)
+	_, err := c.FetchScoresContext(context.Background(), throughputQuery)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, s.Requests())
+
+	s.SetStatus(http.StatusInternalServerError)
+	_, err = c.FetchScoresContext(context.Background(), throughputQuery)
+	assert.True(t, errors.Is(err, oclient.ErrOracleUnavailable))
+}
+
+func TestInjectedLatency(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := newClient(t, s).FetchScoresContext(ctx, throughputQuery)
+	assert.Error(t, err)
+}
+
+func TestValidateRejectsReports(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Validate = func(report oracle.Report) error {
+		if report.Metadata.Application == "" {
+			return errors.New("no application")
+		}
+		return nil
+	}
+	c := newClient(t, s)
+
+	err := c.ReportStatsContext(context.Background(), oracle.Report{PathFp: "fp", DstIA: dst})
+	assert.True(t, errors.Is(err, oclient.ErrReportRejected))
+	report := oracle.Report{PathFp: "fp", DstIA: dst, Metadata: oracle.Metadata{Application: "test"}}
+	assert.NoError(t, c.ReportStatsContext(context.Background(), report))
+	assert.Len(t, s.Reports(), 1)
+}
+
+func TestCompactEncodings(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetScore(dst, "fp", "throughput", 10)
+	c := newClient(t, s, oclient.WithEncoding(oclient.EncodingConfig{CBOR: true, Gzip: true}))
+
+	for i := 0; i < 2; i++ {
+		res, err := c.FetchScoresContext(context.Background(), throughputQuery)
+		require.NoError(t, err)
+		assert.Equal(t, 10., res[dst][0].Scores["throughput"])
+	}
+	require.NoError(t, c.ReportStatsContext(context.Background(), oracle.Report{PathFp: "fp", DstIA: dst}))
+	require.NoError(t, c.ReportStatsBatchContext(context.Background(), []oracle.Report{{PathFp: "fp2", DstIA: dst}}))
+	assert.Equal(t, []string{"application/json", "application/cbor+gzip", "application/cbor+gzip",
+		"application/cbor+gzip"}, s.Encodings())
+	assert.Equal(t, oracle.PathFingerprint("fp"), s.Reports()[0].PathFp)
+	assert.Equal(t, dst, s.Reports()[1].DstIA)
+
+	legacy := NewServer()
+	defer legacy.Close()
+	legacy.JSONOnly(true)
+	c = newClient(t, legacy, oclient.WithEncoding(oclient.DefaultEncodingConfig))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.ReportStatsContext(context.Background(), oracle.Report{PathFp: "fp", DstIA: dst}))
+	}
+	assert.Equal(t, []string{"application/json", "application/json"}, legacy.Encodings())
+}
+
+func TestCapabilities(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	caps, err := newClient(t, s).Capabilities(context.Background())
+	require.NoError(t, err)
+	assert.True(t, caps.Legacy)
+
+	s.SetCapabilities(oclient.Capabilities{Versions: []int{2}})
+	_, err = newClient(t, s).Capabilities(context.Background())
+	assert.ErrorIs(t, err, oclient.ErrIncompatibleOracle)
+
+	s.SetCapabilities(oclient.Capabilities{Versions: []int{1}, Services: []services.ServiceName{"throughput"}})
+	c := newClient(t, s, oclient.WithCapabilities())
+	s.SetScore(dst, "fp", "throughput", 10)
+	res, err := c.FetchScoresContext(context.Background(), throughputQuery)
+	require.NoError(t, err)
+	assert.Len(t, res[dst], 1)
+	require.NoError(t, c.ReportStatsContext(context.Background(), oracle.Report{DstIA: dst, PathFp: "fp"}))
+	assert.Len(t, s.Reports(), 1)
+}
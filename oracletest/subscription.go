@@ -0,0 +1,118 @@
+package oracletest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/scionproto/scion/go/lib/addr"
+	"net/http"
+)
+
+// subscriber is a client watching the scores of some destinations via server-sent events.
+type subscriber struct {
+	dsts map[addr.IA]bool
+	// notify is signalled when a score of a watched destination changed
+	notify chan struct{}
+	// closed is closed to end the subscription
+	closed chan struct{}
+}
+
+// Subscribers returns the number of open subscriptions.
+func (o *Oracle) Subscribers() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.subscribers)
+}
+
+// CloseSubscriptions ends all open subscriptions, e.g. to test reconnects.
+func (o *Oracle) CloseSubscriptions() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.closeSubscriptions()
+}
+
+func (o *Oracle) stopStreams() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.streamsStopped = true
+	o.closeSubscriptions()
+}
+
+func (o *Oracle) closeSubscriptions() {
+	for sub := range o.subscribers {
+		close(sub.closed)
+		delete(o.subscribers, sub)
+	}
+}
+
+// notify wakes up all subscribers watching dst. The caller must hold the mutex.
+func (o *Oracle) notify(dst addr.IA) {
+	for sub := range o.subscribers {
+		if !sub.dsts[dst] {
+			continue
+		}
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (o *Oracle) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	var q server.ScoringQuery
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := &subscriber{dsts: make(map[addr.IA]bool), notify: make(chan struct{}, 1), closed: make(chan struct{})}
+	for rawDst := range q.Queries {
+		dst, err := addr.IAFromString(rawDst)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sub.dsts[dst] = true
+	}
+	o.mutex.Lock()
+	if o.streamsStopped {
+		o.mutex.Unlock()
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	o.subscribers[sub] = struct{}{}
+	o.mutex.Unlock()
+	defer func() {
+		o.mutex.Lock()
+		delete(o.subscribers, sub)
+		o.mutex.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		res, err := o.scoringResponse(q)
+		if err != nil {
+			return
+		}
+		data, _ := json.Marshal(res)
+		if _, err := fmt.Fprintf(w, "event: scores\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-sub.notify:
+		case <-sub.closed:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Package privacy limits what reports reveal to the operator of a path oracle. Reports can have their durations and
+// timestamps coarsened, and their measured properties perturbed with Laplace noise satisfying epsilon-differential
+// privacy. The privacy loss is accounted per destination against a budget, reports exceeding it are dropped.
+//
+// The source IA of a report is not part of the request body, the path oracle learns the source AS from the SCION
+// connection a report is submitted on. Coarsening or dropping the source IA therefore does not hide it from the path
+// oracle, but from everything the client keeps of a report: spooled and quarantined reports, and interval files.
+package privacy
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"oclient"
+	"oclient/internal/props"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExhausted is returned for reports to a destination whose privacy budget is spent.
+var ErrBudgetExhausted = errors.New("privacy budget of destination is exhausted")
+
+// SrcIAMode decides how much of the source IA of a report the client keeps. It does not change what the path oracle
+// learns, see the package documentation.
+type SrcIAMode int
+
+const (
+	// SrcIAKeep keeps the source IA.
+	SrcIAKeep SrcIAMode = iota
+	// SrcIACoarsen keeps the ISD of the source only.
+	SrcIACoarsen
+	// SrcIADrop removes the source IA.
+	SrcIADrop
+)
+
+func (m SrcIAMode) String() string {
+	switch m {
+	case SrcIAKeep:
+		return "keep"
+	case SrcIACoarsen:
+		return "coarsen"
+	case SrcIADrop:
+		return "drop"
+	}
+	return fmt.Sprintf("SrcIAMode(%d)", int(m))
+}
+
+// Apply returns what the mode keeps of ia.
+func (m SrcIAMode) Apply(ia addr.IA) addr.IA {
+	switch m {
+	case SrcIACoarsen:
+		return addr.IA{I: ia.I}
+	case SrcIADrop:
+		return addr.IA{}
+	}
+	return ia
+}
+
+// ParseSrcIAMode parses the string representation of a SrcIAMode, e.g. coarsen.
+func ParseSrcIAMode(s string) (SrcIAMode, error) {
+	for _, m := range []SrcIAMode{SrcIAKeep, SrcIACoarsen, SrcIADrop} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown source IA mode %q", s)
+}
+
+// Config selects the anonymization steps applied to reports.
+type Config struct {
+	// SrcIA decides how much of the source IA is kept in reports persisted by the client.
+	SrcIA SrcIAMode
+	// DurationBucket rounds the duration of reports to a multiple of it. 0 keeps the exact duration.
+	DurationBucket time.Duration
+	// TimestampBucket floors the TimestampProperties of the report metadata to a multiple of it.
+	TimestampBucket time.Duration
+	// TimestampProperties are metadata properties holding Unix timestamps in seconds.
+	TimestampProperties []string
+
+	// Epsilon is the privacy loss of a single report. It is split evenly among the noised properties. 0 disables
+	// noise.
+	Epsilon float64
+	// Sensitivity is the maximum influence of a single connection on a monitored property, e.g. the maximum
+	// throughput. Numeric properties without a sensitivity are removed from reports if noise is enabled.
+	Sensitivity map[string]float64
+	// NonNegative clamps noised properties at 0.
+	NonNegative bool
+	// Budget is the total privacy loss allowed per destination and BudgetPeriod. 0 allows an unlimited loss.
+	Budget float64
+	// BudgetPeriod is the time after which the budget of a destination is renewed. 0 never renews it.
+	BudgetPeriod time.Duration
+
+	// Metrics counts reports dropped because of an exhausted budget, if not nil.
+	Metrics *oclient.Metrics
+}
+
+// DefaultConfig coarsens the source IA and durations, and perturbs the throughput with a privacy loss of 0.5 per
+// report and 10 per destination and day.
+var DefaultConfig = Config{
+	SrcIA:               SrcIACoarsen,
+	DurationBucket:      10 * time.Second,
+	TimestampBucket:     time.Minute,
+	TimestampProperties: []string{"timestamp", "begin", "end"},
+	Epsilon:             0.5,
+	Sensitivity:         map[string]float64{"throughput": 1e7},
+	NonNegative:         true,
+	Budget:              10,
+	BudgetPeriod:        24 * time.Hour,
+}
+
+// budget is the privacy loss spent on a destination since start.
+type budget struct {
+	start time.Time
+	spent float64
+}
+
+// Anonymizer applies a Config to reports, tracking the privacy budget of every destination.
+type Anonymizer struct {
+	config Config
+
+	mutex   sync.Mutex
+	budgets map[addr.IA]*budget
+
+	// uniform returns a uniformly distributed number in [0, 1)
+	uniform func() float64
+	now     func() time.Time
+}
+
+func NewAnonymizer(config Config) *Anonymizer {
+	return &Anonymizer{config: config, budgets: make(map[addr.IA]*budget), uniform: cryptoUniform, now: time.Now}
+}
+
+// Remaining returns the privacy budget left for dst, +Inf if the budget is unlimited.
+func (a *Anonymizer) Remaining(dst addr.IA) float64 {
+	if a.config.Budget <= 0 {
+		return math.Inf(1)
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.config.Budget - a.budget(dst).spent
+}
+
+// Anonymize returns an anonymized copy of report. It fails with ErrBudgetExhausted if the privacy loss of the
+// report exceeds the remaining budget of its destination.
+func (a *Anonymizer) Anonymize(report oracle.Report) (oracle.Report, error) {
+	if err := a.spend(report.DstIA); err != nil {
+		return report, err
+	}
+
+	report.SrcIA = a.config.SrcIA.Apply(report.SrcIA)
+	if b := a.config.DurationBucket.Seconds(); b > 0 {
+		report.Metadata.Duration = math.Max(b, math.Round(report.Metadata.Duration/b)*b)
+	}
+	report.Metadata.Properties = a.coarsenTimestamps(report.Metadata.Properties)
+	if a.config.Epsilon > 0 {
+		report.Properties = a.perturb(report.Properties)
+	}
+	return report, nil
+}
+
+// spend charges the privacy loss of a report to the budget of dst.
+func (a *Anonymizer) spend(dst addr.IA) error {
+	if a.config.Budget <= 0 || a.config.Epsilon <= 0 {
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	b := a.budget(dst)
+	if b.spent+a.config.Epsilon > a.config.Budget {
+		a.config.Metrics.ReportDropped(oclient.DropPrivacyBudget)
+		return fmt.Errorf("%w: %s", ErrBudgetExhausted, dst)
+	}
+	b.spent += a.config.Epsilon
+	return nil
+}
+
+// budget returns the budget of dst, renewing it if its period elapsed.
+func (a *Anonymizer) budget(dst addr.IA) *budget {
+	now := a.now()
+	b, ok := a.budgets[dst]
+	if !ok || (a.config.BudgetPeriod > 0 && now.Sub(b.start) >= a.config.BudgetPeriod) {
+		b = &budget{start: now}
+		a.budgets[dst] = b
+	}
+	return b
+}
+
+func (a *Anonymizer) coarsenTimestamps(properties oracle.MetadataProperties) oracle.MetadataProperties {
+	b := a.config.TimestampBucket.Seconds()
+	if b <= 0 || len(properties) == 0 {
+		return properties
+	}
+	coarsened := make(oracle.MetadataProperties, len(properties))
+	for k, v := range properties {
+		coarsened[k] = v
+	}
+	for _, k := range a.config.TimestampProperties {
+		if ts, ok := props.Number(properties[k]); ok {
+			coarsened[k] = math.Floor(ts/b) * b
+		}
+	}
+	return coarsened
+}
+
+// perturb adds Laplace noise to the numeric properties, scaled by their sensitivity and their share of Epsilon.
+func (a *Anonymizer) perturb(properties oracle.MonitoredProperties) oracle.MonitoredProperties {
+	noised := 0
+	for k, v := range properties {
+		if _, ok := props.Number(v); ok && a.config.Sensitivity[k] > 0 {
+			noised++
+		}
+	}
+	perturbed := make(oracle.MonitoredProperties, len(properties))
+	for k, v := range properties {
+		value, ok := props.Number(v)
+		if !ok {
+			perturbed[k] = v
+			continue
+		}
+		sensitivity := a.config.Sensitivity[k]
+		if sensitivity <= 0 {
+			continue
+		}
+		value += a.laplace(sensitivity * float64(noised) / a.config.Epsilon)
+		if a.config.NonNegative {
+			value = math.Max(0, value)
+		}
+		perturbed[k] = value
+	}
+	return perturbed
+}
+
+// laplace samples the Laplace distribution centered at 0 with the given scale.
+func (a *Anonymizer) laplace(scale float64) float64 {
+	u := a.uniform()
+	for u == 0 {
+		// log(0) is not defined
+		u = a.uniform()
+	}
+	u -= 0.5
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+// cryptoUniform returns a uniformly distributed number in [0, 1) read from crypto/rand, so the noise cannot be
+// predicted from the seed of a pseudo-random generator.
+func cryptoUniform() float64 {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	return float64(binary.BigEndian.Uint64(buf[:])>>11) / (1 << 53)
+}
+
+// flusher is implemented by StatsReporters queueing reports for delivery in the background.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Reporter anonymizes all reports before passing them on to the next StatsReporter.
+type Reporter struct {
+	anonymizer *Anonymizer
+	next       oclient.StatsReporter
+}
+
+func NewReporter(next oclient.StatsReporter, anonymizer *Anonymizer) *Reporter {
+	return &Reporter{anonymizer: anonymizer, next: next}
+}
+
+func (r *Reporter) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	anonymized, err := r.anonymizer.Anonymize(report)
+	if err != nil {
+		return err
+	}
+	return r.next.ReportStatsContext(ctx, anonymized)
+}
+
+// ReportStatsBatchContext anonymizes all reports and passes them on as a batch, leaving out reports exceeding the
+// budget of their destination. It returns oclient.ErrBatchUnsupported if the next StatsReporter does not support
+// batches, and ErrBudgetExhausted if no report is left.
+func (r *Reporter) ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) error {
+	batch, ok := r.next.(oclient.BatchStatsReporter)
+	if !ok {
+		return oclient.ErrBatchUnsupported
+	}
+	anonymized := make([]oracle.Report, 0, len(reports))
+	var err error
+	for _, report := range reports {
+		var a oracle.Report
+		if a, err = r.anonymizer.Anonymize(report); err == nil {
+			anonymized = append(anonymized, a)
+		}
+	}
+	if len(anonymized) == 0 {
+		return err
+	}
+	return batch.ReportStatsBatchContext(ctx, anonymized)
+}
+
+// Flush blocks until the next StatsReporter delivered its queued reports, if it queues them, or ctx is done.
+func (r *Reporter) Flush(ctx context.Context) error {
+	if f, ok := r.next.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Unwrap returns the next StatsReporter.
+func (r *Reporter) Unwrap() oclient.StatsReporter {
+	return r.next
+}
@@ -0,0 +1,147 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"oclient"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testReport() oracle.Report {
+	return oracle.Report{
+		Metadata: oracle.Metadata{
+			Application: "quic_sender",
+			Duration:    37.2,
+			Properties:  oracle.MetadataProperties{"timestamp": 1634470123., "protocols": []string{"SCION"}},
+		},
+		Properties: oracle.MonitoredProperties{"throughput": 1250000., "loss": 0.01, "cc": "cubic"},
+		SrcIA:      addr.IA{I: 1, A: 0xff0000000110},
+		DstIA:      addr.IA{I: 2, A: 13},
+		PathFp:     "a b",
+	}
+}
+
+func TestAnonymizeCoarsens(t *testing.T) {
+	config := DefaultConfig
+	config.Epsilon = 0
+	a := NewAnonymizer(config)
+	report := testReport()
+
+	anonymized, err := a.Anonymize(report)
+	require.NoError(t, err)
+	assert.Equal(t, addr.IA{I: 1}, anonymized.SrcIA)
+	assert.Equal(t, 40., anonymized.Metadata.Duration)
+	assert.Equal(t, 1634470080., anonymized.Metadata.Properties["timestamp"])
+	assert.Equal(t, report.Properties, anonymized.Properties)
+	assert.Equal(t, 1634470123., report.Metadata.Properties["timestamp"], "original report must not be modified")
+
+	config.SrcIA = SrcIADrop
+	anonymized, err = NewAnonymizer(config).Anonymize(report)
+	require.NoError(t, err)
+	assert.True(t, anonymized.SrcIA.IsZero())
+}
+
+func TestPerturbAddsLaplaceNoise(t *testing.T) {
+	config := Config{Epsilon: 2, Sensitivity: map[string]float64{"throughput": 1e6, "loss": 1}, NonNegative: true}
+	a := NewAnonymizer(config)
+	// the 0.75 quantile of the Laplace distribution is scale * ln(2)
+	a.uniform = func() float64 { return 0.75 }
+
+	anonymized, err := a.Anonymize(testReport())
+	require.NoError(t, err)
+	// epsilon is split among both numeric properties, hence the scale is sensitivity * 2 / 2
+	assert.InDelta(t, 1250000+1e6*math.Ln2, anonymized.Properties["throughput"], 1e-6)
+	assert.InDelta(t, 0.01+math.Ln2, anonymized.Properties["loss"], 1e-9)
+	assert.Equal(t, "cubic", anonymized.Properties["cc"])
+
+	a.uniform = func() float64 { return 0.001 }
+	anonymized, err = a.Anonymize(testReport())
+	require.NoError(t, err)
+	assert.Equal(t, 0., anonymized.Properties["loss"], "noised properties are clamped at 0")
+
+	delete(a.config.Sensitivity, "loss")
+	anonymized, err = a.Anonymize(testReport())
+	require.NoError(t, err)
+	assert.NotContains(t, anonymized.Properties, "loss", "properties without sensitivity must not be sent")
+}
+
+func TestNoiseIsUnbiased(t *testing.T) {
+	a := NewAnonymizer(Config{Epsilon: 1, Sensitivity: map[string]float64{"throughput": 10}})
+	sum, n := 0., 20000
+	for i := 0; i < n; i++ {
+		sum += a.laplace(10)
+	}
+	// the standard deviation of the mean is sqrt(2) * 10 / sqrt(n) = 0.1
+	assert.InDelta(t, 0, sum/float64(n), 0.5)
+}
+
+func TestBudgetPerDestination(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := oclient.NewMetrics(registry)
+	require.NoError(t, err)
+	now := time.Unix(0, 0)
+	a := NewAnonymizer(Config{Epsilon: 1, Sensitivity: map[string]float64{"throughput": 1}, Budget: 2,
+		BudgetPeriod: time.Hour, Metrics: metrics})
+	a.now = func() time.Time { return now }
+
+	report := testReport()
+	for i := 0; i < 2; i++ {
+		_, err := a.Anonymize(report)
+		require.NoError(t, err)
+	}
+	_, err = a.Anonymize(report)
+	assert.True(t, errors.Is(err, ErrBudgetExhausted))
+	assert.Equal(t, 0., a.Remaining(report.DstIA))
+
+	other := testReport()
+	other.DstIA = addr.IA{I: 2, A: 14}
+	_, err = a.Anonymize(other)
+	assert.NoError(t, err, "budgets are tracked per destination")
+
+	now = now.Add(time.Hour)
+	_, err = a.Anonymize(report)
+	assert.NoError(t, err, "budget is renewed after its period")
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP path_oracle_client_reports_dropped_total Reports discarded before delivery by reason.
+# TYPE path_oracle_client_reports_dropped_total counter
+path_oracle_client_reports_dropped_total{reason="privacy_budget"} 1
+`), "path_oracle_client_reports_dropped_total"))
+}
+
+type recordingReporter struct {
+	reports []oracle.Report
+}
+
+func (r *recordingReporter) ReportStatsContext(_ context.Context, report oracle.Report) error {
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func (r *recordingReporter) ReportStatsBatchContext(_ context.Context, reports []oracle.Report) error {
+	r.reports = append(r.reports, reports...)
+	return nil
+}
+
+func TestReporterSkipsExhaustedReports(t *testing.T) {
+	next := &recordingReporter{}
+	r := NewReporter(next, NewAnonymizer(Config{SrcIA: SrcIADrop, Epsilon: 1,
+		Sensitivity: map[string]float64{"throughput": 1}, Budget: 1}))
+
+	require.NoError(t, r.ReportStatsBatchContext(context.Background(), []oracle.Report{testReport(), testReport()}))
+	require.Len(t, next.reports, 1)
+	assert.True(t, next.reports[0].SrcIA.IsZero())
+
+	err := r.ReportStatsContext(context.Background(), testReport())
+	assert.True(t, errors.Is(err, ErrBudgetExhausted))
+	assert.Len(t, next.reports, 1)
+}
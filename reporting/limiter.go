@@ -0,0 +1,271 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"oclient"
+	"oclient/internal/props"
+	"sync"
+	"time"
+)
+
+// flusher is implemented by StatsReporters queueing reports for delivery in the background, like Reporter.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// sampleSmoothing is the weight of a new throughput in the moving average a path's stability is judged by.
+const sampleSmoothing = 0.3
+
+type LimiterConfig struct {
+	// DestinationRate is the number of reports per second sustained to a destination IA. 0 for no limit.
+	DestinationRate float64
+	// DestinationBurst is the number of reports to a destination IA allowed at once.
+	DestinationBurst int
+	// PathRate is the number of reports per second sustained on a path. 0 for no limit.
+	PathRate float64
+	// PathBurst is the number of reports on a path allowed at once.
+	PathBurst int
+
+	// StableFraction is the fraction of reports submitted on a path whose throughput is stable. 0 or 1 disable
+	// sampling.
+	StableFraction float64
+	// StableTolerance is the deviation of the throughput from its moving average, relative to the latter, which
+	// is still considered stable.
+	StableTolerance float64
+	// StableAfter is the number of consecutive stable reports after which sampling starts. Any unstable report
+	// is submitted and ends sampling.
+	StableAfter int
+
+	// IdleTimeout forgets the state of paths and destinations not reported on for this time.
+	IdleTimeout time.Duration
+	// Metrics counts suppressed reports, if not nil.
+	Metrics *oclient.Metrics
+}
+
+// DefaultLimiterConfig allows a report per second to every destination and one every 10 seconds on every path,
+// and submits every fourth report once the throughput of a path stayed within 10% for 3 reports.
+var DefaultLimiterConfig = LimiterConfig{
+	DestinationRate:  1,
+	DestinationBurst: 10,
+	PathRate:         0.1,
+	PathBurst:        3,
+	StableFraction:   0.25,
+	StableTolerance:  0.1,
+	StableAfter:      3,
+	IdleTimeout:      time.Hour,
+}
+
+// LimiterStats counts the reports seen by a Limiter.
+type LimiterStats struct {
+	// Passed reports were handed to the next StatsReporter.
+	Passed uint64
+	// RateLimited reports exceeded the rate of their destination or path.
+	RateLimited uint64
+	// Sampled reports were left out because the throughput of their path is stable.
+	Sampled uint64
+}
+
+// tokenBucket holds up to burst tokens, refilled at rate tokens per second.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// available refills the bucket and returns whether a token is available.
+func (b *tokenBucket) available(now time.Time, rate float64, burst int) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+type pathKey struct {
+	dst addr.IA
+	fp  oracle.PathFingerprint
+}
+
+// pathState is the rate limit and sampling state of a path.
+type pathState struct {
+	bucket tokenBucket
+	// average is the moving average of the throughput, stable the number of consecutive reports close to it
+	average float64
+	stable  int
+	// credit accumulates StableFraction per sampled report, a report is submitted once it reaches 1
+	credit float64
+	seen   time.Time
+}
+
+// Limiter protects the path oracle from floods of reports, e.g. by many connections reporting in short intervals.
+// Reports exceeding the token bucket of their destination IA or path, and most reports on paths with a stable
+// throughput, are not passed on to the next StatsReporter but fail with oclient.ErrReportSuppressed. A single Limiter
+// is meant to be shared by all connections of a process.
+type Limiter struct {
+	config LimiterConfig
+	next   oclient.StatsReporter
+
+	mutex        sync.Mutex
+	destinations map[addr.IA]*tokenBucket
+	paths        map[pathKey]*pathState
+	lastSweep    time.Time
+	stats        LimiterStats
+
+	now func() time.Time
+}
+
+func NewLimiter(next oclient.StatsReporter, config LimiterConfig) *Limiter {
+	return &Limiter{
+		config:       config,
+		next:         next,
+		destinations: make(map[addr.IA]*tokenBucket),
+		paths:        make(map[pathKey]*pathState),
+		now:          time.Now,
+	}
+}
+
+// Stats returns the number of passed and suppressed reports.
+func (l *Limiter) Stats() LimiterStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
+
+func (l *Limiter) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	if err := l.admit(report); err != nil {
+		return err
+	}
+	return l.next.ReportStatsContext(ctx, report)
+}
+
+// ReportStatsBatchContext passes the admitted reports on as a batch. It returns oclient.ErrBatchUnsupported if the
+// next StatsReporter does not support batches, and oclient.ErrReportSuppressed if no report is admitted.
+func (l *Limiter) ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) error {
+	batch, ok := l.next.(oclient.BatchStatsReporter)
+	if !ok {
+		return oclient.ErrBatchUnsupported
+	}
+	admitted := make([]oracle.Report, 0, len(reports))
+	var err error
+	for _, report := range reports {
+		if err = l.admit(report); err == nil {
+			admitted = append(admitted, report)
+		}
+	}
+	if len(admitted) == 0 {
+		return err
+	}
+	return batch.ReportStatsBatchContext(ctx, admitted)
+}
+
+// Flush blocks until the next StatsReporter delivered its queued reports, if it queues them, or ctx is done.
+func (l *Limiter) Flush(ctx context.Context) error {
+	if f, ok := l.next.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// Unwrap returns the next StatsReporter.
+func (l *Limiter) Unwrap() oclient.StatsReporter {
+	return l.next
+}
+
+// admit decides whether report is passed on. Sampling is decided first, so reports left out do not use up tokens.
+func (l *Limiter) admit(report oracle.Report) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	key := pathKey{dst: report.DstIA, fp: report.PathFp}
+	path, ok := l.paths[key]
+	if !ok {
+		path = &pathState{}
+		l.paths[key] = path
+	}
+	path.seen = now
+	if !path.sample(report, l.config) {
+		l.stats.Sampled++
+		l.config.Metrics.ReportDropped(oclient.DropSampled)
+		return fmt.Errorf("%w: throughput of path %q to %s is stable", oclient.ErrReportSuppressed, report.PathFp,
+			report.DstIA)
+	}
+
+	dst, ok := l.destinations[report.DstIA]
+	if !ok {
+		dst = &tokenBucket{}
+		l.destinations[report.DstIA] = dst
+	}
+	dstOK := l.config.DestinationRate <= 0 || dst.available(now, l.config.DestinationRate, l.config.DestinationBurst)
+	pathOK := l.config.PathRate <= 0 || path.bucket.available(now, l.config.PathRate, l.config.PathBurst)
+	if !dstOK || !pathOK {
+		l.stats.RateLimited++
+		l.config.Metrics.ReportDropped(oclient.DropRateLimited)
+		if !dstOK {
+			return fmt.Errorf("%w: rate limit of destination %s exceeded", oclient.ErrReportSuppressed, report.DstIA)
+		}
+		return fmt.Errorf("%w: rate limit of path %q to %s exceeded", oclient.ErrReportSuppressed, report.PathFp,
+			report.DstIA)
+	}
+	if l.config.DestinationRate > 0 {
+		dst.tokens--
+	}
+	if l.config.PathRate > 0 {
+		path.bucket.tokens--
+	}
+	l.stats.Passed++
+	return nil
+}
+
+// sample updates the throughput average of the path and returns whether report is submitted.
+func (p *pathState) sample(report oracle.Report, config LimiterConfig) bool {
+	throughput, ok := props.Number(report.Properties["throughput"])
+	if !ok || config.StableFraction <= 0 || config.StableFraction >= 1 {
+		return true
+	}
+	if p.average > 0 && math.Abs(throughput-p.average) <= config.StableTolerance*p.average {
+		p.stable++
+	} else {
+		p.stable = 0
+	}
+	if p.average > 0 {
+		p.average += sampleSmoothing * (throughput - p.average)
+	} else {
+		p.average = throughput
+	}
+
+	if p.stable < config.StableAfter {
+		p.credit = 0
+		return true
+	}
+	p.credit += config.StableFraction
+	if p.credit >= 1 {
+		p.credit--
+		return true
+	}
+	return false
+}
+
+// sweep forgets idle paths and destinations, at most once per IdleTimeout.
+func (l *Limiter) sweep(now time.Time) {
+	if l.config.IdleTimeout <= 0 || now.Sub(l.lastSweep) < l.config.IdleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, path := range l.paths {
+		if now.Sub(path.seen) >= l.config.IdleTimeout {
+			delete(l.paths, key)
+		}
+	}
+	for dst, bucket := range l.destinations {
+		if now.Sub(bucket.last) >= l.config.IdleTimeout {
+			delete(l.destinations, dst)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package reporting
+
+import (
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oclient"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose clock is advanced by the returned function.
+func newTestLimiter(next oclient.StatsReporter, config LimiterConfig) (*Limiter, func(time.Duration)) {
+	l := NewLimiter(next, config)
+	now := time.Unix(1641031200, 0)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func throughputReport(fp string, throughput float64) oracle.Report {
+	r := testReport(fp)
+	r.Properties = oracle.MonitoredProperties{"throughput": throughput}
+	return r
+}
+
+func TestLimiterRateLimitsPaths(t *testing.T) {
+	next := &reporterMock{}
+	l, advance := newTestLimiter(next, LimiterConfig{PathRate: 1, PathBurst: 2})
+	ctx := context.Background()
+
+	require.NoError(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	require.NoError(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	assert.ErrorIs(t, l.ReportStatsContext(ctx, testReport("1 2")), oclient.ErrReportSuppressed)
+	// paths have their own buckets
+	assert.NoError(t, l.ReportStatsContext(ctx, testReport("3 4")))
+
+	advance(time.Second)
+	assert.NoError(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	assert.Error(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	assert.Equal(t, LimiterStats{Passed: 4, RateLimited: 2}, l.Stats())
+	assert.Equal(t, 4, next.count())
+}
+
+func TestLimiterRateLimitsDestinations(t *testing.T) {
+	next := &reporterMock{}
+	l, advance := newTestLimiter(next, LimiterConfig{DestinationRate: 0.5, DestinationBurst: 1, PathRate: 1, PathBurst: 1})
+	ctx := context.Background()
+
+	require.NoError(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	assert.ErrorIs(t, l.ReportStatsContext(ctx, testReport("3 4")), oclient.ErrReportSuppressed)
+	other := testReport("3 4")
+	other.DstIA = addr.IA{I: 1, A: 14}
+	assert.NoError(t, l.ReportStatsContext(ctx, other))
+
+	// the path is allowed another report, but the destination is not yet
+	advance(time.Second)
+	assert.Error(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	advance(time.Second)
+	assert.NoError(t, l.ReportStatsContext(ctx, testReport("3 4")))
+	assert.Equal(t, 3, next.count())
+}
+
+func TestLimiterSamplesStablePaths(t *testing.T) {
+	next := &reporterMock{}
+	l, _ := newTestLimiter(next, LimiterConfig{StableFraction: 0.5, StableTolerance: 0.1, StableAfter: 2})
+	ctx := context.Background()
+
+	var submitted []bool
+	for _, throughput := range []float64{100, 102, 98, 101, 99, 100, 200, 100} {
+		err := l.ReportStatsContext(ctx, throughputReport("1 2", throughput))
+		if err != nil {
+			assert.ErrorIs(t, err, oclient.ErrReportSuppressed)
+		}
+		submitted = append(submitted, err == nil)
+	}
+	// stable after the third report, every second one is submitted until the throughput jumps
+	assert.Equal(t, []bool{true, true, false, true, false, true, true, true}, submitted)
+	assert.Equal(t, uint64(2), l.Stats().Sampled)
+}
+
+func TestLimiterSamplesBeforeRateLimiting(t *testing.T) {
+	l, _ := newTestLimiter(&reporterMock{}, LimiterConfig{
+		PathRate: 1, PathBurst: 1, StableFraction: 0.5, StableTolerance: 0.1, StableAfter: 1,
+	})
+	ctx := context.Background()
+	require.NoError(t, l.ReportStatsContext(ctx, throughputReport("1 2", 100)))
+	// sampled out, the exhausted bucket is not consulted
+	assert.Error(t, l.ReportStatsContext(ctx, throughputReport("1 2", 100)))
+	assert.Equal(t, LimiterStats{Passed: 1, Sampled: 1}, l.Stats())
+}
+
+func TestLimiterForgetsIdlePaths(t *testing.T) {
+	l, advance := newTestLimiter(&reporterMock{}, LimiterConfig{PathRate: 0.001, PathBurst: 1, IdleTimeout: time.Minute})
+	ctx := context.Background()
+	require.NoError(t, l.ReportStatsContext(ctx, testReport("1 2")))
+	advance(time.Minute)
+	require.NoError(t, l.ReportStatsContext(ctx, testReport("3 4")))
+	assert.Len(t, l.paths, 1)
+}
+
+func TestLimiterBatches(t *testing.T) {
+	next := &batchReporterMock{}
+	l, _ := newTestLimiter(next, LimiterConfig{PathRate: 1, PathBurst: 1})
+	ctx := context.Background()
+	require.NoError(t, l.ReportStatsBatchContext(ctx, []oracle.Report{testReport("1 2"), testReport("1 2")}))
+	assert.ErrorIs(t, l.ReportStatsBatchContext(ctx, []oracle.Report{testReport("1 2")}), oclient.ErrReportSuppressed)
+	assert.Len(t, next.batches, 1)
+
+	l = NewLimiter(&reporterMock{}, DefaultLimiterConfig)
+	assert.ErrorIs(t, l.ReportStatsBatchContext(ctx, []oracle.Report{testReport("1 2")}), oclient.ErrBatchUnsupported)
+}
@@ -0,0 +1,86 @@
+package reporting
+
+import (
+	"encoding/json"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// quarantineRecord is a single line of a quarantine file.
+type quarantineRecord struct {
+	Report        oracle.Report          `json:"report"`
+	SrcIA         addr.IA                `json:"src_ia"`
+	DstIA         addr.IA                `json:"dst_ia"`
+	PathFp        oracle.PathFingerprint `json:"path_fp"`
+	Reason        string                 `json:"reason"`
+	QuarantinedAt time.Time              `json:"quarantined_at"`
+}
+
+// QuarantineFile appends invalid reports along with the reason they were rejected to a file, one JSON object
+// per line. It implements oclient.Quarantine.
+type QuarantineFile struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewQuarantineFile opens the file at path for appending, creating it if needed.
+func NewQuarantineFile(path string) (*QuarantineFile, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &QuarantineFile{file: f}, nil
+}
+
+func (q *QuarantineFile) Quarantine(report oracle.Report, reason error) error {
+	// NaN and infinite numbers are a common reason for quarantining, but cannot be encoded as JSON
+	props := make(oracle.MonitoredProperties, len(report.Properties))
+	for k, v := range report.Properties {
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			v = strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		props[k] = v
+	}
+	report.Properties = props
+	duration := report.Metadata.Duration
+	if math.IsNaN(duration) || math.IsInf(duration, 0) {
+		report.Metadata.Duration = 0
+	}
+
+	line, err := json.Marshal(quarantineRecord{
+		Report:        report,
+		SrcIA:         report.SrcIA,
+		DstIA:         report.DstIA,
+		PathFp:        report.PathFp,
+		Reason:        reason.Error(),
+		QuarantinedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.file == nil {
+		return errors.New("quarantine file is closed")
+	}
+	_, err = q.file.Write(append(line, '\n'))
+	return err
+}
+
+func (q *QuarantineFile) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
@@ -0,0 +1,41 @@
+package reporting
+
+import (
+	"encoding/json"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"oclient"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineFileKeepsNonFiniteReports(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quarantine.jsonl")
+	q, err := NewQuarantineFile(path)
+	require.NoError(t, err)
+
+	report := oracle.Report{
+		Properties: oracle.MonitoredProperties{"throughput": math.Inf(1)},
+		DstIA:      addr.IA{I: 1, A: 13},
+	}
+	reason := oclient.DefaultReportSchema.Validate(report)
+	require.Error(t, reason)
+	require.NoError(t, q.Quarantine(report, reason))
+	require.NoError(t, q.Close())
+	assert.Error(t, q.Quarantine(report, reason))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+	var rec quarantineRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	assert.Equal(t, report.DstIA, rec.DstIA)
+	assert.Equal(t, "+Inf", rec.Report.Properties["throughput"])
+	assert.Contains(t, rec.Reason, "missing path fingerprint")
+}
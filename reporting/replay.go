@@ -0,0 +1,258 @@
+package reporting
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"go.uber.org/zap"
+	"io"
+	"oclient"
+	"strconv"
+	"time"
+)
+
+// Interval is a measurement interval of a connection, as reported by the tracers and written to the interval file
+// by tracers.CsvStatsWriter.
+type Interval struct {
+	Begin, End  time.Time
+	Fingerprint oracle.PathFingerprint
+	BytesSent   uint64
+	Throughput  float64
+	// SrcIA and DstIA are zero if the file was written before they were recorded.
+	SrcIA, DstIA addr.IA
+}
+
+// Report returns the report on the throughput of the interval, attributed to application.
+func (i Interval) Report(application string) oracle.Report {
+	return oracle.Report{
+		Metadata: oracle.Metadata{
+			Application: application,
+			Duration:    i.End.Sub(i.Begin).Seconds(),
+			Properties: oracle.MetadataProperties{
+				"protocols":             []string{"SCION", "UDP", "QUIC"},
+				"taps-capacity-profile": "capacity-seeking",
+			},
+		},
+		Properties: oracle.MonitoredProperties{
+			"throughput": i.Throughput,
+		},
+		SrcIA:  i.SrcIA,
+		DstIA:  i.DstIA,
+		PathFp: i.Fingerprint,
+	}
+}
+
+// ReadIntervals parses an interval file. Columns are looked up by the names of the header, so files of older
+// versions without the src_ia and dst_ia columns can be read as well. Repeated header lines, as written by every
+// connection appending to the same file, are skipped.
+func ReadIntervals(r io.Reader) ([]Interval, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"begin_unx", "end_unx", "fingerprint", "throughput"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	var intervals []Interval
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return intervals, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == header[0] {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		interval, err := parseInterval(record, columns)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		intervals = append(intervals, interval)
+	}
+}
+
+func parseInterval(record []string, columns map[string]int) (Interval, error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	var i Interval
+	begin, err := strconv.ParseInt(field("begin_unx"), 10, 64)
+	if err != nil {
+		return i, err
+	}
+	end, err := strconv.ParseInt(field("end_unx"), 10, 64)
+	if err != nil {
+		return i, err
+	}
+	i.Begin, i.End = time.Unix(begin, 0), time.Unix(end, 0)
+	if i.Throughput, err = strconv.ParseFloat(field("throughput"), 64); err != nil {
+		return i, err
+	}
+	if s := field("bytes_sent"); s != "" {
+		if i.BytesSent, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return i, err
+		}
+	}
+	for name, ia := range map[string]*addr.IA{"src_ia": &i.SrcIA, "dst_ia": &i.DstIA} {
+		// the zero IA is written as 0-0
+		if s := field(name); s != "" && s != (addr.IA{}).String() {
+			if *ia, err = addr.IAFromString(s); err != nil {
+				return i, err
+			}
+		}
+	}
+	i.Fingerprint = oracle.PathFingerprint(field("fingerprint"))
+	return i, nil
+}
+
+type ReplayConfig struct {
+	// SrcIA and DstIA replace the IAs recorded in the intervals if not zero. Intervals without a destination are
+	// rejected.
+	SrcIA, DstIA addr.IA
+	// Shift is added to the begin and end of all intervals.
+	Shift time.Duration
+	// StartAt shifts the intervals such that the earliest one begins at it, if not zero. It takes precedence over
+	// Shift.
+	StartAt time.Time
+	// Rate is the maximum number of reports submitted per second. 0 for no limit.
+	Rate float64
+	// DryRun only reconstructs the reports without submitting them.
+	DryRun bool
+	// Application is the application the reports are attributed to.
+	Application string
+}
+
+// DefaultReplayConfig submits up to 10 reports per second, attributed to the quic_sender which writes the
+// interval files.
+var DefaultReplayConfig = ReplayConfig{
+	Rate:        10,
+	Application: "quic_sender",
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	// Reports are the reconstructed reports, in the order of the intervals.
+	Reports []oracle.Report
+	// Skipped counts intervals before the first path was selected, which cannot be reported.
+	Skipped int
+	// Submitted counts the reports accepted by the oracle.
+	Submitted int
+	// Rejected counts the reports rejected by the oracle or the validation of the client.
+	Rejected int
+}
+
+// Replayer feeds the intervals of historical runs into a path oracle.
+type Replayer struct {
+	next   oclient.StatsReporter
+	config ReplayConfig
+	logger *zap.SugaredLogger
+}
+
+func NewReplayer(next oclient.StatsReporter, config ReplayConfig, logger *zap.SugaredLogger) *Replayer {
+	return &Replayer{next: next, config: config, logger: logger}
+}
+
+// Reports reconstructs the reports of intervals as they were submitted live, applying the IAs of the config. The
+// shifted begin and end of the interval and a replayed marker are added to the metadata properties.
+func (r *Replayer) Reports(intervals []Interval) ([]oracle.Report, int, error) {
+	shift := r.config.Shift
+	if !r.config.StartAt.IsZero() && len(intervals) > 0 {
+		earliest := intervals[0].Begin
+		for _, i := range intervals[1:] {
+			if i.Begin.Before(earliest) {
+				earliest = i.Begin
+			}
+		}
+		shift = r.config.StartAt.Sub(earliest)
+	}
+
+	var reports []oracle.Report
+	skipped := 0
+	for n, i := range intervals {
+		if i.Fingerprint == "" {
+			// interval before the first path was selected
+			skipped++
+			continue
+		}
+		report := i.Report(r.config.Application)
+		report.Metadata.Properties["begin"] = float64(i.Begin.Add(shift).Unix())
+		report.Metadata.Properties["end"] = float64(i.End.Add(shift).Unix())
+		report.Metadata.Properties["replayed"] = true
+		if !r.config.SrcIA.IsZero() {
+			report.SrcIA = r.config.SrcIA
+		}
+		if !r.config.DstIA.IsZero() {
+			report.DstIA = r.config.DstIA
+		}
+		if report.DstIA.IsZero() {
+			return nil, 0, fmt.Errorf("interval %d: no destination IA recorded or configured", n+1)
+		}
+		reports = append(reports, report)
+	}
+	return reports, skipped, nil
+}
+
+// Replay reconstructs the reports of intervals and submits them, at most Rate per second. Reports rejected by the
+// oracle are counted and skipped, any other error aborts the replay.
+func (r *Replayer) Replay(ctx context.Context, intervals []Interval) (ReplayResult, error) {
+	var result ReplayResult
+	var err error
+	if result.Reports, result.Skipped, err = r.Reports(intervals); err != nil {
+		return result, err
+	}
+	if r.config.DryRun {
+		return result, nil
+	}
+
+	var interval time.Duration
+	if r.config.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / r.config.Rate)
+	}
+	next := time.Now()
+	for _, report := range result.Reports {
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return result, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		next = time.Now().Add(interval)
+
+		err := r.next.ReportStatsContext(ctx, report)
+		switch {
+		case err == nil:
+			result.Submitted++
+		case errors.Is(err, oclient.ErrBadRequest) || errors.Is(err, oclient.ErrReportRejected) ||
+			errors.Is(err, oclient.ErrInvalidReport):
+			r.logger.Warnw("replayed report rejected", "error", err, "dst", report.DstIA, "fingerprint", report.PathFp)
+			result.Rejected++
+		default:
+			return result, fmt.Errorf("reporting path %q: %w", report.PathFp, err)
+		}
+	}
+	return result, nil
+}
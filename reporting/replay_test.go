@@ -0,0 +1,117 @@
+package reporting
+
+import (
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"oclient"
+	"strings"
+	"testing"
+	"time"
+)
+
+const intervalFile = `begin,end,begin_unx,end_unx,fingerprint,bytes_sent,throughput,src_ia,dst_ia
+2022-01-01T10:00:00+0000,2022-01-01T10:00:10+0000,1641031200,1641031210,,0,0,1-ff00:0:111,1-ff00:0:112
+2022-01-01T10:00:10+0000,2022-01-01T10:00:20+0000,1641031210,1641031220,1 2,1000,100,1-ff00:0:111,1-ff00:0:112
+begin,end,begin_unx,end_unx,fingerprint,bytes_sent,throughput,src_ia,dst_ia
+2022-01-01T10:00:30+0000,2022-01-01T10:00:40+0000,1641031230,1641031240,3 4,2000,200,1-ff00:0:111,1-ff00:0:112
+`
+
+func TestReadIntervals(t *testing.T) {
+	intervals, err := ReadIntervals(strings.NewReader(intervalFile))
+	require.NoError(t, err)
+	require.Len(t, intervals, 3)
+	assert.Equal(t, Interval{
+		Begin:       time.Unix(1641031210, 0),
+		End:         time.Unix(1641031220, 0),
+		Fingerprint: "1 2",
+		BytesSent:   1000,
+		Throughput:  100,
+		SrcIA:       addr.IA{I: 1, A: 0xff0000000111},
+		DstIA:       addr.IA{I: 1, A: 0xff0000000112},
+	}, intervals[1])
+
+	// files written before the IAs were recorded
+	old := "begin,end,begin_unx,end_unx,fingerprint,bytes_sent,throughput\n" +
+		"2022-01-01T10:00:10+0000,2022-01-01T10:00:20+0000,1641031210,1641031220,1 2,1000,100\n"
+	intervals, err = ReadIntervals(strings.NewReader(old))
+	require.NoError(t, err)
+	require.Len(t, intervals, 1)
+	assert.True(t, intervals[0].DstIA.IsZero())
+
+	_, err = ReadIntervals(strings.NewReader("begin,end\n"))
+	assert.Error(t, err)
+	_, err = ReadIntervals(strings.NewReader(old + "x,y,z,1641031220,1 2,1000,100\n"))
+	assert.Error(t, err)
+}
+
+func TestReplayShiftsAndOverridesIAs(t *testing.T) {
+	intervals, err := ReadIntervals(strings.NewReader(intervalFile))
+	require.NoError(t, err)
+	dst := addr.IA{I: 2, A: 0xff0000000210}
+	startAt := time.Unix(1700000000, 0)
+	next := &reporterMock{}
+	config := ReplayConfig{DstIA: dst, StartAt: startAt, Application: "quic_sender"}
+	result, err := NewReplayer(next, config, zap.NewNop().Sugar()).Replay(context.Background(), intervals)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, 2, result.Submitted)
+	reported := next.reports()
+	require.Len(t, reported, 2)
+	r := reported[1]
+	assert.Equal(t, dst, r.DstIA)
+	assert.Equal(t, addr.IA{I: 1, A: 0xff0000000111}, r.SrcIA)
+	assert.Equal(t, oracle.PathFingerprint("3 4"), r.PathFp)
+	assert.Equal(t, 10., r.Metadata.Duration)
+	assert.Equal(t, 200., r.Properties["throughput"])
+	assert.Equal(t, float64(startAt.Unix()+30), r.Metadata.Properties["begin"])
+	assert.Equal(t, true, r.Metadata.Properties["replayed"])
+	// the metadata of live reports is kept
+	assert.Equal(t, intervals[2].Report("quic_sender").Metadata.Properties["protocols"], r.Metadata.Properties["protocols"])
+	assert.Equal(t, "capacity-seeking", r.Metadata.Properties["taps-capacity-profile"])
+	assert.NoError(t, oclient.DefaultReportSchema.Validate(r))
+}
+
+func TestReplayDryRun(t *testing.T) {
+	intervals, err := ReadIntervals(strings.NewReader(intervalFile))
+	require.NoError(t, err)
+	next := &reporterMock{}
+	config := ReplayConfig{DryRun: true, Shift: time.Hour}
+	result, err := NewReplayer(next, config, zap.NewNop().Sugar()).Replay(context.Background(), intervals)
+	require.NoError(t, err)
+	assert.Len(t, result.Reports, 2)
+	assert.Equal(t, float64(1641031210+3600), result.Reports[0].Metadata.Properties["begin"])
+	assert.Zero(t, result.Submitted)
+	assert.Zero(t, next.count())
+}
+
+func TestReplayRequiresDestination(t *testing.T) {
+	intervals := []Interval{{Begin: time.Unix(0, 0), End: time.Unix(10, 0), Fingerprint: "1 2", Throughput: 1}}
+	_, err := NewReplayer(&reporterMock{}, DefaultReplayConfig, zap.NewNop().Sugar()).Replay(context.Background(), intervals)
+	assert.Error(t, err)
+}
+
+func TestReplayRateLimits(t *testing.T) {
+	intervals := make([]Interval, 4)
+	for i := range intervals {
+		intervals[i] = Interval{Fingerprint: "1 2", Throughput: 1, DstIA: addr.IA{I: 1, A: 13}}
+	}
+	next := &reporterMock{}
+	config := ReplayConfig{Rate: 20}
+	start := time.Now()
+	result, err := NewReplayer(next, config, zap.NewNop().Sugar()).Replay(context.Background(), intervals)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Submitted)
+	// the first report is submitted right away
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = NewReplayer(next, config, zap.NewNop().Sugar()).Replay(ctx, intervals)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, result.Submitted)
+}
@@ -0,0 +1,270 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"go.uber.org/zap"
+	"oclient"
+	"sync"
+	"time"
+)
+
+// ErrReporterClosed is returned when submitting reports to a closed Reporter.
+var ErrReporterClosed = errors.New("reporter is closed")
+
+// BackpressurePolicy decides what happens to new reports while the queue of a Reporter is full.
+type BackpressurePolicy int
+
+const (
+	// DropOldest discards the oldest queued report in favour of the new one.
+	DropOldest BackpressurePolicy = iota
+	// Block waits until there is room in the queue or the context of the submission is done.
+	Block
+)
+
+type ReporterConfig struct {
+	// QueueSize is the number of reports which may wait for submission.
+	QueueSize int
+	// Workers is the number of reports (or batches) submitted concurrently.
+	Workers int
+	// BatchSize > 1 combines up to BatchSize reports in a single request, if the next reporter supports it.
+	BatchSize int
+	// BatchDelay is the maximum time a report waits for further reports to fill its batch.
+	BatchDelay time.Duration
+	// Backpressure is applied when reports are submitted faster than they can be delivered.
+	Backpressure BackpressurePolicy
+	// SendTimeout bounds the submission of a single report or batch. 0 to wait indefinitely.
+	SendTimeout time.Duration
+	// Metrics counts dropped reports, if not nil.
+	Metrics *oclient.Metrics
+}
+
+// DefaultReporterConfig submits reports one by one using 4 workers.
+var DefaultReporterConfig = ReporterConfig{
+	QueueSize:    256,
+	Workers:      4,
+	BatchSize:    1,
+	BatchDelay:   time.Second,
+	Backpressure: DropOldest,
+	SendTimeout:  30 * time.Second,
+}
+
+// Reporter decouples the submission of reports from their delivery. Reports are queued and delivered to the next
+// StatsReporter by a fixed pool of workers. A single Reporter is meant to be shared by all connections of a process.
+type Reporter struct {
+	config ReporterConfig
+	next   oclient.StatsReporter
+	batch  oclient.BatchStatsReporter
+	logger *zap.SugaredLogger
+
+	queue chan oracle.Report
+
+	mutex   sync.Mutex
+	pending int
+	idle    chan struct{}
+	closed  bool
+	dropped uint64
+	// batching is disabled once the next reporter turns out not to support batches
+	batching bool
+
+	stop    chan struct{}
+	workers sync.WaitGroup
+}
+
+// NewReporter starts the workers delivering queued reports to next.
+func NewReporter(next oclient.StatsReporter, config ReporterConfig, logger *zap.SugaredLogger) *Reporter {
+	if config.QueueSize < 1 {
+		config.QueueSize = DefaultReporterConfig.QueueSize
+	}
+	if config.Workers < 1 {
+		config.Workers = DefaultReporterConfig.Workers
+	}
+
+	r := &Reporter{
+		config: config,
+		next:   next,
+		logger: logger,
+		queue:  make(chan oracle.Report, config.QueueSize),
+		idle:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	if b, ok := next.(oclient.BatchStatsReporter); ok && config.BatchSize > 1 {
+		r.batch = b
+		r.batching = true
+	}
+
+	r.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// ReportStatsContext queues report for delivery. Depending on the BackpressurePolicy, a full queue either drops the
+// oldest queued report or blocks until ctx is done.
+func (r *Reporter) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return ErrReporterClosed
+	}
+	r.pending++
+	r.mutex.Unlock()
+
+	if r.config.Backpressure == Block {
+		select {
+		case r.queue <- report:
+			return nil
+		case <-ctx.Done():
+			r.done(1)
+			return ctx.Err()
+		}
+	}
+
+	for {
+		select {
+		case r.queue <- report:
+			return nil
+		default:
+		}
+		select {
+		case old := <-r.queue:
+			r.mutex.Lock()
+			r.dropped++
+			r.mutex.Unlock()
+			r.config.Metrics.ReportDropped(oclient.DropQueueFull)
+			r.done(1)
+			r.logger.Warnw("report queue full, dropping oldest report", "fingerprint", old.PathFp)
+		default:
+		}
+	}
+}
+
+// Flush blocks until all queued reports were delivered (or given up on) or ctx is done.
+func (r *Reporter) Flush(ctx context.Context) error {
+	r.mutex.Lock()
+	if r.pending == 0 {
+		r.mutex.Unlock()
+		return nil
+	}
+	idle := r.idle
+	r.mutex.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting reports, flushes the queue within the limits of ctx and stops the workers.
+func (r *Reporter) Close(ctx context.Context) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	r.mutex.Unlock()
+
+	err := r.Flush(ctx)
+	close(r.stop)
+	r.workers.Wait()
+	return err
+}
+
+// Dropped returns the number of reports discarded because the queue was full.
+func (r *Reporter) Dropped() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.dropped
+}
+
+// done marks n reports as no longer pending and wakes up flushing goroutines once the queue is drained.
+func (r *Reporter) done(n int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pending -= n
+	if r.pending == 0 {
+		close(r.idle)
+		r.idle = make(chan struct{})
+	}
+}
+
+func (r *Reporter) work() {
+	defer r.workers.Done()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case report := <-r.queue:
+			reports := r.collectBatch(report)
+			r.send(reports)
+			r.done(len(reports))
+		}
+	}
+}
+
+// collectBatch completes a batch starting with first from the queue, waiting at most BatchDelay for further reports.
+func (r *Reporter) collectBatch(first oracle.Report) []oracle.Report {
+	reports := []oracle.Report{first}
+	if !r.batchingEnabled() {
+		return reports
+	}
+
+	timeout := time.NewTimer(r.config.BatchDelay)
+	defer timeout.Stop()
+	for len(reports) < r.config.BatchSize {
+		select {
+		case report := <-r.queue:
+			reports = append(reports, report)
+		case <-timeout.C:
+			return reports
+		case <-r.stop:
+			return reports
+		}
+	}
+	return reports
+}
+
+func (r *Reporter) send(reports []oracle.Report) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if r.config.SendTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.config.SendTimeout)
+	}
+	defer cancel()
+
+	if len(reports) > 1 {
+		err := r.batch.ReportStatsBatchContext(ctx, reports)
+		if err == nil {
+			r.logger.Debugw("submitted batch of reports", "size", len(reports))
+			return
+		}
+		if !errors.Is(err, oclient.ErrBatchUnsupported) {
+			r.logger.Warnw("error submitting batch of reports", "error", err, "size", len(reports))
+			for range reports {
+				r.config.Metrics.ReportDropped(oclient.DropFailed)
+			}
+			return
+		}
+		r.logger.Infow("oracle does not support batches, submitting reports individually")
+		r.mutex.Lock()
+		r.batching = false
+		r.mutex.Unlock()
+	}
+
+	for _, report := range reports {
+		if err := r.next.ReportStatsContext(ctx, report); err != nil {
+			r.logger.Warnw("error submitting report", "error", err, "fingerprint", report.PathFp)
+			r.config.Metrics.ReportDropped(oclient.DropFailed)
+		}
+	}
+}
+
+func (r *Reporter) batchingEnabled() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.batching
+}
@@ -0,0 +1,115 @@
+package reporting
+
+import (
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"oclient"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchReporterMock records the size of each submitted batch.
+type batchReporterMock struct {
+	reporterMock
+	batchMutex  sync.Mutex
+	unsupported bool
+	batches     []int
+}
+
+func (r *batchReporterMock) ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) error {
+	r.batchMutex.Lock()
+	defer r.batchMutex.Unlock()
+	if r.unsupported {
+		return oclient.ErrBatchUnsupported
+	}
+	r.batches = append(r.batches, len(reports))
+	return nil
+}
+
+func TestReporterFlush(t *testing.T) {
+	next := &reporterMock{}
+	r := NewReporter(next, ReporterConfig{QueueSize: 10, Workers: 2}, zap.S())
+
+	for _, fp := range []string{"a", "b", "c"} {
+		assert.NoError(t, r.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	assert.NoError(t, r.Flush(context.Background()))
+	assert.Equal(t, 3, next.count())
+
+	assert.NoError(t, r.Close(context.Background()))
+	assert.ErrorIs(t, r.ReportStatsContext(context.Background(), testReport("d")), ErrReporterClosed)
+}
+
+func TestReporterBatches(t *testing.T) {
+	next := &batchReporterMock{}
+	r := NewReporter(next, ReporterConfig{QueueSize: 10, Workers: 1, BatchSize: 3, BatchDelay: time.Hour}, zap.S())
+	defer r.Close(context.Background())
+
+	for _, fp := range []string{"a", "b", "c"} {
+		assert.NoError(t, r.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	assert.NoError(t, r.Flush(context.Background()))
+	assert.Equal(t, []int{3}, next.batches)
+}
+
+func TestReporterFallsBackToSingleReports(t *testing.T) {
+	next := &batchReporterMock{unsupported: true}
+	r := NewReporter(next, ReporterConfig{QueueSize: 10, Workers: 1, BatchSize: 2, BatchDelay: time.Hour}, zap.S())
+	defer r.Close(context.Background())
+
+	for _, fp := range []string{"a", "b", "c"} {
+		assert.NoError(t, r.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	assert.NoError(t, r.Flush(context.Background()))
+	assert.Equal(t, 3, next.count())
+}
+
+func TestReporterDropsOldest(t *testing.T) {
+	next := &reporterMock{received: make(chan oracle.Report, 1)}
+	next.mutex.Lock()
+	registry := prometheus.NewRegistry()
+	metrics, err := oclient.NewMetrics(registry)
+	assert.NoError(t, err)
+	r := NewReporter(next, ReporterConfig{QueueSize: 1, Workers: 1, Backpressure: DropOldest, Metrics: metrics},
+		zap.S())
+
+	// the worker blocks on the first report, the queue holds only one of the remaining reports
+	assert.NoError(t, r.ReportStatsContext(context.Background(), testReport("a")))
+	<-next.received
+	for _, fp := range []string{"b", "c"} {
+		assert.NoError(t, r.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	next.mutex.Unlock()
+	assert.NoError(t, r.Close(context.Background()))
+
+	assert.Equal(t, uint64(1), r.Dropped())
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP path_oracle_client_reports_dropped_total Reports discarded before delivery by reason.
+# TYPE path_oracle_client_reports_dropped_total counter
+path_oracle_client_reports_dropped_total{reason="queue_full"} 1
+`), "path_oracle_client_reports_dropped_total"))
+	reported := next.reports()
+	assert.Equal(t, oracle.PathFingerprint("a"), reported[0].PathFp)
+	assert.Equal(t, oracle.PathFingerprint("c"), reported[1].PathFp)
+}
+
+func TestReporterBlocks(t *testing.T) {
+	next := &reporterMock{received: make(chan oracle.Report, 1)}
+	next.mutex.Lock()
+	defer next.mutex.Unlock()
+	r := NewReporter(next, ReporterConfig{QueueSize: 1, Workers: 1, Backpressure: Block}, zap.S())
+
+	assert.NoError(t, r.ReportStatsContext(context.Background(), testReport("a")))
+	<-next.received
+	assert.NoError(t, r.ReportStatsContext(context.Background(), testReport("b")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, r.ReportStatsContext(ctx, testReport("c")), context.DeadlineExceeded)
+}
@@ -0,0 +1,459 @@
+package reporting
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"go.uber.org/zap"
+	"io"
+	"io/ioutil"
+	"oclient"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	segmentSuffix = ".journal"
+	cursorFile    = "cursor"
+)
+
+type SpoolConfig struct {
+	// Dir is the directory holding the journal, it is created if it does not exist.
+	Dir string
+	// MaxBytes caps the size of the journal, the oldest reports are dropped first. 0 for no limit.
+	MaxBytes int64
+	// MaxAge drops reports which could not be delivered within this time. 0 for no limit.
+	MaxAge time.Duration
+	// SegmentSize is the size after which a new journal segment is started.
+	SegmentSize int64
+	// RetryInterval is the time waited after a failed delivery before trying again.
+	RetryInterval time.Duration
+	// SendTimeout bounds the delivery of a single report. 0 to wait indefinitely.
+	SendTimeout time.Duration
+	// Metrics counts spooled and dropped reports, if not nil.
+	Metrics *oclient.Metrics
+}
+
+// DefaultSpoolConfig keeps up to 64MiB of reports for at most a week.
+var DefaultSpoolConfig = SpoolConfig{
+	MaxBytes:      64 << 20,
+	MaxAge:        7 * 24 * time.Hour,
+	SegmentSize:   1 << 20,
+	RetryInterval: 10 * time.Second,
+	SendTimeout:   30 * time.Second,
+}
+
+// spoolRecord is a single line of a journal segment. In contrast to the report's JSON representation,
+// it contains the path parameters of the report.
+type spoolRecord struct {
+	Report    oracle.Report          `json:"report"`
+	SrcIA     addr.IA                `json:"src_ia"`
+	DstIA     addr.IA                `json:"dst_ia"`
+	PathFp    oracle.PathFingerprint `json:"path_fp"`
+	SpooledAt time.Time              `json:"spooled_at"`
+}
+
+// Spool persists reports in an append-only journal on disk and delivers them to the next StatsReporter in the
+// background, retrying until delivery succeeds. Undelivered reports survive restarts of the process.
+type Spool struct {
+	config SpoolConfig
+	next   oclient.StatsReporter
+	logger *zap.SugaredLogger
+
+	mutex      sync.Mutex
+	closed     bool
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSpool opens (or creates) the journal in config.Dir and starts delivering spooled reports to next.
+func NewSpool(next oclient.StatsReporter, config SpoolConfig, logger *zap.SugaredLogger) (*Spool, error) {
+	if config.Dir == "" {
+		return nil, errors.New("no spool directory configured")
+	}
+	if config.SegmentSize <= 0 {
+		config.SegmentSize = DefaultSpoolConfig.SegmentSize
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = DefaultSpoolConfig.RetryInterval
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &Spool{config: config, next: next, logger: logger, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		s.activeSeq = segments[len(segments)-1]
+	}
+	// never append to segments of a previous run, they might end with a partially written record
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		logger.Infow("resuming delivery of spooled reports", "segments", len(segments), "dir", config.Dir)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.drain(ctx)
+	return s, nil
+}
+
+// ReportStatsContext appends report to the journal. It returns as soon as the report is persisted,
+// delivery happens asynchronously.
+func (s *Spool) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	line, err := json.Marshal(spoolRecord{
+		Report:    report,
+		SrcIA:     report.SrcIA,
+		DstIA:     report.DstIA,
+		PathFp:    report.PathFp,
+		SpooledAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errors.New("spool is closed")
+	}
+	if s.activeSize > 0 && s.activeSize+int64(len(line)) > s.config.SegmentSize {
+		if err := s.rotate(); err != nil {
+			// the active segment grows beyond SegmentSize until a new one can be opened
+			s.logger.Warnw("error rotating spool segment, appending to the active one", "error", err)
+		}
+	}
+	if _, err := s.active.Write(line); err != nil {
+		s.discardPartialWrite()
+		return err
+	}
+	s.activeSize += int64(len(line))
+	if err := s.active.Sync(); err != nil {
+		return err
+	}
+	s.config.Metrics.ReportSpooled()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close stops the delivery of spooled reports. Undelivered reports remain in the journal.
+func (s *Spool) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.active.Close()
+}
+
+// rotate starts a new segment and closes the active one. If the new segment cannot be created, the active one
+// is kept. The caller must hold the mutex.
+func (s *Spool) rotate() error {
+	f, err := os.OpenFile(s.segmentPath(s.activeSeq+1), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			s.logger.Warnw("error closing spool segment", "error", err, "segment", s.activeSeq)
+		}
+	}
+	s.active = f
+	s.activeSeq++
+	s.activeSize = 0
+	return nil
+}
+
+// discardPartialWrite removes a partially written record from the end of the active segment, so the next record
+// does not continue the torn line and get dropped as corrupt along with it. If the segment cannot be truncated,
+// a new segment is started. The caller must hold the mutex.
+func (s *Spool) discardPartialWrite() {
+	err := s.active.Truncate(s.activeSize)
+	if err == nil {
+		return
+	}
+	s.logger.Warnw("error truncating partially written spool record", "error", err, "segment", s.activeSeq)
+	if err := s.rotate(); err != nil {
+		s.logger.Warnw("error rotating spool segment", "error", err)
+	}
+}
+
+// nextSegment returns the oldest segment which is not written to anymore. If only the active segment contains
+// reports, it is rotated. ok is false if there is nothing to deliver.
+func (s *Spool) nextSegment() (seq uint64, ok bool, err error) {
+	segments, err := s.segments()
+	if err != nil || len(segments) == 0 {
+		return 0, false, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if segments[0] != s.activeSeq {
+		return segments[0], true, nil
+	}
+	if s.activeSize == 0 || s.closed {
+		return 0, false, nil
+	}
+	seq = s.activeSeq
+	if err := s.rotate(); err != nil {
+		// the active segment must not be delivered while it is written to
+		return 0, false, err
+	}
+	return seq, true, nil
+}
+
+func (s *Spool) drain(ctx context.Context) {
+	defer close(s.done)
+	for {
+		s.enforceLimits()
+
+		seq, ok, err := s.nextSegment()
+		if err != nil {
+			s.logger.Warnw("error reading spool", "error", err)
+		}
+		if ok {
+			err = s.deliverSegment(ctx, seq)
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Infow("could not deliver spooled reports, retrying later",
+				"error", err, "retry_in", s.config.RetryInterval)
+		}
+
+		var retry <-chan time.Time
+		if ok || err != nil {
+			retry = time.After(s.config.RetryInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+			if retry != nil {
+				// new reports do not indicate that the oracle is reachable again
+				select {
+				case <-ctx.Done():
+					return
+				case <-retry:
+				}
+			}
+		case <-retry:
+		}
+	}
+}
+
+// deliverSegment sends all reports of segment seq starting at the persisted cursor and removes the segment
+// once all reports were delivered.
+func (s *Spool) deliverSegment(ctx context.Context, seq uint64) error {
+	offset, err := s.readCursor(seq)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a trailing line without newline is a partially written record
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.deliver(ctx, line); err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		if err := s.writeCursor(seq, offset); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(s.segmentPath(seq)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.config.Dir, cursorFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deliver sends a single journal line. Records which are corrupt, expired or rejected by the oracle are dropped.
+func (s *Spool) deliver(ctx context.Context, line []byte) error {
+	var rec spoolRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		s.logger.Warnw("dropping corrupt spool record", "error", err)
+		s.config.Metrics.ReportDropped(oclient.DropCorrupt)
+		return nil
+	}
+	if s.config.MaxAge > 0 && time.Since(rec.SpooledAt) > s.config.MaxAge {
+		s.logger.Infow("dropping expired spooled report", "spooled_at", rec.SpooledAt, "fingerprint", rec.PathFp)
+		s.config.Metrics.ReportDropped(oclient.DropExpired)
+		return nil
+	}
+
+	report := rec.Report
+	report.SrcIA, report.DstIA, report.PathFp = rec.SrcIA, rec.DstIA, rec.PathFp
+
+	sendCtx, cancel := ctx, context.CancelFunc(func() {})
+	if s.config.SendTimeout > 0 {
+		sendCtx, cancel = context.WithTimeout(ctx, s.config.SendTimeout)
+	}
+	defer cancel()
+	err := s.next.ReportStatsContext(sendCtx, report)
+	if errors.Is(err, oclient.ErrBadRequest) || errors.Is(err, oclient.ErrReportRejected) ||
+		errors.Is(err, oclient.ErrInvalidReport) {
+		// retrying would not change the oracle's mind
+		s.logger.Warnw("dropping spooled report rejected by oracle", "error", err, "fingerprint", report.PathFp)
+		s.config.Metrics.ReportDropped(oclient.DropRejected)
+		return nil
+	}
+	return err
+}
+
+// enforceLimits removes whole segments, oldest first, which exceed MaxBytes or only contain reports older
+// than MaxAge. The active segment is never removed.
+func (s *Spool) enforceLimits() {
+	segments, err := s.segments()
+	if err != nil {
+		return
+	}
+	s.mutex.Lock()
+	activeSeq := s.activeSeq
+	s.mutex.Unlock()
+
+	var total int64
+	infos := make([]os.FileInfo, len(segments))
+	for i, seq := range segments {
+		if infos[i], err = os.Stat(s.segmentPath(seq)); err != nil {
+			return
+		}
+		total += infos[i].Size()
+	}
+
+	for i, seq := range segments {
+		if seq == activeSeq {
+			break
+		}
+		expired := s.config.MaxAge > 0 && time.Since(infos[i].ModTime()) > s.config.MaxAge
+		oversized := s.config.MaxBytes > 0 && total > s.config.MaxBytes
+		if !expired && !oversized {
+			break
+		}
+		s.logger.Warnw("dropping spooled reports", "segment", seq, "bytes", infos[i].Size(),
+			"expired", expired, "oversized", oversized)
+		records := s.countRecords(seq)
+		if err := os.Remove(s.segmentPath(seq)); err != nil {
+			return
+		}
+		reason := oclient.DropSpoolFull
+		if expired {
+			reason = oclient.DropExpired
+		}
+		for i := 0; i < records; i++ {
+			s.config.Metrics.ReportDropped(reason)
+		}
+		total -= infos[i].Size()
+	}
+}
+
+// countRecords returns the number of records in a segment, 0 if it cannot be read.
+func (s *Spool) countRecords(seq uint64) int {
+	data, err := ioutil.ReadFile(s.segmentPath(seq))
+	if err != nil {
+		return 0
+	}
+	return bytes.Count(data, []byte{'\n'})
+}
+
+// segments returns the sequence numbers of all journal segments in ascending order.
+func (s *Spool) segments() ([]uint64, error) {
+	entries, err := ioutil.ReadDir(s.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (s *Spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.config.Dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+// readCursor returns the offset of the first undelivered record in segment seq.
+func (s *Spool) readCursor(seq uint64) (int64, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.config.Dir, cursorFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var cSeq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(b), "%d %d", &cSeq, &offset); err != nil || cSeq != seq {
+		// the cursor belongs to a segment which was dropped in the meantime
+		return 0, nil
+	}
+	return offset, nil
+}
+
+func (s *Spool) writeCursor(seq uint64, offset int64) error {
+	path := filepath.Join(s.config.Dir, cursorFile)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", seq, offset)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
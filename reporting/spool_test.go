@@ -0,0 +1,162 @@
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reporterMock records delivered reports and fails while failing is set. If received is not nil, every report is
+// sent to it as it arrives, before the mutex is taken, unless the channel is full.
+type reporterMock struct {
+	mutex    sync.Mutex
+	failing  bool
+	reported []oracle.Report
+	received chan oracle.Report
+}
+
+func (r *reporterMock) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	select {
+	case r.received <- report:
+	default:
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.failing {
+		return errors.New("oracle unreachable")
+	}
+	r.reported = append(r.reported, report)
+	return nil
+}
+
+func (r *reporterMock) setFailing(failing bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.failing = failing
+}
+
+func (r *reporterMock) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.reported)
+}
+
+func (r *reporterMock) reports() []oracle.Report {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]oracle.Report(nil), r.reported...)
+}
+
+func testReport(fp string) oracle.Report {
+	return oracle.Report{
+		Properties: oracle.MonitoredProperties{"throughput": 42.},
+		SrcIA:      addr.IA{I: 1, A: 12},
+		DstIA:      addr.IA{I: 1, A: 13},
+		PathFp:     oracle.PathFingerprint(fp),
+	}
+}
+
+func TestSpoolSurvivesRestart(t *testing.T) {
+	config := SpoolConfig{Dir: t.TempDir(), RetryInterval: 10 * time.Millisecond}
+	next := &reporterMock{failing: true, received: make(chan oracle.Report, 1)}
+
+	spool, err := NewSpool(next, config, zap.S())
+	assert.NoError(t, err)
+	assert.NoError(t, spool.ReportStatsContext(context.Background(), testReport("a")))
+	assert.NoError(t, spool.ReportStatsContext(context.Background(), testReport("b")))
+	// the spool tried to deliver while the oracle is unreachable
+	<-next.received
+	assert.NoError(t, spool.Close())
+	assert.Equal(t, 0, next.count())
+
+	next.setFailing(false)
+	spool, err = NewSpool(next, config, zap.S())
+	assert.NoError(t, err)
+	defer spool.Close()
+
+	assert.Eventually(t, func() bool { return next.count() == 2 }, time.Second, 10*time.Millisecond)
+	reported := next.reports()
+	assert.Equal(t, testReport("a"), reported[0])
+	assert.Equal(t, oracle.PathFingerprint("b"), reported[1].PathFp)
+
+	segments, err := spool.segments()
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1, "only the active segment remains")
+}
+
+func TestSpoolDropsOldestWhenFull(t *testing.T) {
+	record, _ := json.Marshal(spoolRecord{Report: testReport("a"), SpooledAt: time.Now()})
+	// every report gets its own segment, only the latest fits into the journal
+	config := SpoolConfig{Dir: t.TempDir(), RetryInterval: time.Hour, SegmentSize: 1, MaxBytes: int64(len(record)) * 3 / 2}
+	next := &reporterMock{failing: true}
+
+	spool, err := NewSpool(next, config, zap.S())
+	assert.NoError(t, err)
+	for _, fp := range []string{"a", "b", "c"} {
+		assert.NoError(t, spool.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	assert.NoError(t, spool.Close())
+
+	next.setFailing(false)
+	spool, err = NewSpool(next, config, zap.S())
+	assert.NoError(t, err)
+	defer spool.Close()
+
+	assert.Eventually(t, func() bool { return next.count() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, oracle.PathFingerprint("c"), next.reports()[0].PathFp)
+}
+
+func TestSpoolDiscardsPartialWrites(t *testing.T) {
+	config := SpoolConfig{Dir: t.TempDir(), RetryInterval: time.Hour}
+	next := &reporterMock{failing: true}
+	spool, err := NewSpool(next, config, zap.S())
+	require.NoError(t, err)
+	require.NoError(t, spool.ReportStatsContext(context.Background(), testReport("a")))
+
+	// a record torn by a failed write
+	spool.mutex.Lock()
+	_, err = spool.active.Write([]byte(`{"report":`))
+	require.NoError(t, err)
+	spool.discardPartialWrite()
+	spool.mutex.Unlock()
+	require.NoError(t, spool.ReportStatsContext(context.Background(), testReport("b")))
+	require.NoError(t, spool.Close())
+
+	next.setFailing(false)
+	spool, err = NewSpool(next, config, zap.S())
+	require.NoError(t, err)
+	defer spool.Close()
+	assert.Eventually(t, func() bool { return next.count() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestSpoolKeepsActiveSegmentIfRotationFails(t *testing.T) {
+	config := SpoolConfig{Dir: t.TempDir(), RetryInterval: time.Hour, SegmentSize: 1}
+	spool, err := NewSpool(&reporterMock{failing: true}, config, zap.S())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	// a directory in place of the next segment fails its creation
+	spool.mutex.Lock()
+	blocked := spool.segmentPath(spool.activeSeq + 1)
+	spool.mutex.Unlock()
+	require.NoError(t, os.Mkdir(blocked, 0755))
+	for _, fp := range []string{"a", "b"} {
+		assert.NoError(t, spool.ReportStatsContext(context.Background(), testReport(fp)))
+	}
+	assert.Equal(t, 2, spool.countRecords(spool.activeSeq))
+
+	require.NoError(t, os.Remove(blocked))
+	assert.NoError(t, spool.ReportStatsContext(context.Background(), testReport("c")))
+	spool.mutex.Lock()
+	defer spool.mutex.Unlock()
+	assert.Equal(t, 1, spool.countRecords(spool.activeSeq))
+}
@@ -0,0 +1,63 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy defines how often and when failed requests to the path oracle are repeated.
+// Only connection errors and 5xx responses are retried, any other response is final.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts of a request, values < 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the wait time before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponentially growing upper bound of the wait time between attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy retries a request up to two times, waiting up to 200ms and 400ms respectively.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// backoff returns the jittered wait time before the retry following the given (0-based) attempt.
+// The wait time is drawn uniformly from [0, min(MaxBackoff, InitialBackoff * 2^attempt)].
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	upper := float64(p.InitialBackoff) * math.Pow(2, float64(attempt))
+	if p.MaxBackoff > 0 && upper > float64(p.MaxBackoff) {
+		upper = float64(p.MaxBackoff)
+	}
+	if upper < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(upper)))
+}
+
+// isRetryable reports whether the outcome of a request indicates a (possibly transient) unavailability
+// of the path oracle.
+func isRetryable(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		// errors caused by the caller giving up are not an issue of the oracle
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	return res.StatusCode >= 500
+}
+
+// sleep waits for d or until ctx is done, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,252 @@
+package oclient
+
+import (
+	"context"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"go.uber.org/zap"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ScoreCacheConfig struct {
+	// TTL is the time fetched scores are served without contacting the path oracle.
+	TTL time.Duration
+	// StaleWhileRevalidate is the time after TTL during which scores are still served, while they are
+	// refreshed in the background.
+	StaleWhileRevalidate time.Duration
+	// RefreshTimeout bounds fetches of scores. A fetch is shared by all concurrent callers and not aborted when
+	// one of them gives up. 0 to wait indefinitely.
+	RefreshTimeout time.Duration
+}
+
+// DefaultScoreCacheConfig serves scores for a minute and stale scores for further 5 minutes.
+var DefaultScoreCacheConfig = ScoreCacheConfig{
+	TTL:                  time.Minute,
+	StaleWhileRevalidate: 5 * time.Minute,
+	RefreshTimeout:       10 * time.Second,
+}
+
+type fetchFunc func(ctx context.Context, query server.ScoringQuery) (server.ScoringResponse, error)
+
+// scoreKey identifies the scores of a single service for all paths to a destination.
+type scoreKey struct {
+	dst     addr.IA
+	service services.ServiceName
+}
+
+func (k scoreKey) String() string {
+	return k.dst.String() + "/" + string(k.service)
+}
+
+type scoreEntry struct {
+	scores  map[oracle.PathFingerprint]float64
+	fetched time.Time
+}
+
+// scoreCache caches scores per destination and service. Concurrent fetches of the same scores are coalesced
+// into a single request.
+type scoreCache struct {
+	config ScoreCacheConfig
+	logger *zap.SugaredLogger
+
+	mutex   sync.Mutex
+	entries map[scoreKey]scoreEntry
+	flights flightGroup
+	now     func() time.Time
+}
+
+func newScoreCache(config ScoreCacheConfig, logger *zap.SugaredLogger) *scoreCache {
+	return &scoreCache{config: config, logger: logger, entries: make(map[scoreKey]scoreEntry), now: time.Now}
+}
+
+// fetch answers query from the cache, fetching missing or expired scores. Stale scores are answered from the
+// cache and refreshed in the background.
+func (c *scoreCache) fetch(ctx context.Context, query server.ScoringQuery, fetch fetchFunc) (server.ScoringResponse, error) {
+	keys, err := queryKeys(query)
+	if err != nil {
+		// let the path oracle judge malformed queries
+		return fetch(ctx, query)
+	}
+
+	var missing, stale []scoreKey
+	c.mutex.Lock()
+	now := c.now()
+	for _, k := range keys {
+		e, ok := c.entries[k]
+		switch age := now.Sub(e.fetched); {
+		case !ok || age >= c.config.TTL+c.config.StaleWhileRevalidate:
+			missing = append(missing, k)
+		case age >= c.config.TTL:
+			stale = append(stale, k)
+		}
+	}
+	c.mutex.Unlock()
+
+	if len(missing) > 0 {
+		// refresh the stale scores with the same request
+		if err := c.load(ctx, append(missing, stale...), fetch); err != nil {
+			return nil, err
+		}
+	} else if len(stale) > 0 {
+		go c.revalidate(detach(ctx), stale, fetch)
+	}
+	return c.response(keys), nil
+}
+
+func (c *scoreCache) revalidate(ctx context.Context, keys []scoreKey, fetch fetchFunc) {
+	if err := c.load(ctx, keys, fetch); err != nil {
+		c.logger.Infow("error refreshing stale scores", "error", err, "scores", keys)
+	}
+}
+
+// load fetches the scores for keys and stores them in the cache. The fetch is detached from ctx, which only
+// bounds the wait for its result, but keeps its values, e.g. the span of the caller starting the fetch.
+func (c *scoreCache) load(ctx context.Context, keys []scoreKey, fetch fetchFunc) error {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+	}
+	sort.Strings(names)
+
+	return c.flights.do(ctx, strings.Join(names, ","), func() error {
+		q := server.ScoringQuery{Queries: make(map[string][]services.ServiceName)}
+		for _, k := range keys {
+			q.Queries[k.dst.String()] = append(q.Queries[k.dst.String()], k.service)
+		}
+		fetchCtx, cancel := detach(ctx), context.CancelFunc(func() {})
+		if c.config.RefreshTimeout > 0 {
+			fetchCtx, cancel = context.WithTimeout(fetchCtx, c.config.RefreshTimeout)
+		}
+		defer cancel()
+		res, err := fetch(fetchCtx, q)
+		if err != nil {
+			return err
+		}
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		now := c.now()
+		for _, k := range keys {
+			scores := make(map[oracle.PathFingerprint]float64)
+			for _, fs := range res[k.dst] {
+				if score, ok := fs.Scores[string(k.service)]; ok {
+					scores[fs.Fingerprint] = score
+				}
+			}
+			c.entries[k] = scoreEntry{scores: scores, fetched: now}
+		}
+		return nil
+	})
+}
+
+// response assembles the cached scores for keys, scores of paths to the same destination are merged.
+func (c *scoreCache) response(keys []scoreKey) server.ScoringResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	merged := make(map[addr.IA]map[oracle.PathFingerprint]map[string]float64)
+	for _, k := range keys {
+		if merged[k.dst] == nil {
+			merged[k.dst] = make(map[oracle.PathFingerprint]map[string]float64)
+		}
+		for fp, score := range c.entries[k].scores {
+			if merged[k.dst][fp] == nil {
+				merged[k.dst][fp] = make(map[string]float64)
+			}
+			merged[k.dst][fp][string(k.service)] = score
+		}
+	}
+
+	res := make(server.ScoringResponse, len(merged))
+	for dst, paths := range merged {
+		fpScores := make([]server.FingerprintScores, 0, len(paths))
+		for fp, scores := range paths {
+			fpScores = append(fpScores, server.FingerprintScores{Fingerprint: fp, Scores: scores})
+		}
+		sort.Slice(fpScores, func(i, j int) bool { return fpScores[i].Fingerprint < fpScores[j].Fingerprint })
+		res[dst] = fpScores
+	}
+	return res
+}
+
+func queryKeys(query server.ScoringQuery) ([]scoreKey, error) {
+	var keys []scoreKey
+	for rawDst, services := range query.Queries {
+		dst, err := addr.IAFromString(rawDst)
+		if err != nil {
+			return nil, fmt.Errorf("invalid destination %q: %w", rawDst, err)
+		}
+		for _, service := range services {
+			keys = append(keys, scoreKey{dst: dst, service: service})
+		}
+	}
+	return keys, nil
+}
+
+// detachedContext carries the values of a context without its deadline and cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+// detach returns a context with the values of ctx, which is not done when ctx is.
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// flightGroup coalesces concurrent calls with the same key into a single execution.
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+// do executes fn unless a call with the same key is in flight, and awaits the result. fn runs in its own goroutine,
+// so waiting is aborted once ctx is done without aborting fn for the other callers.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() error) error {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.calls[key] = f
+		go func() {
+			f.err = fn()
+			g.mutex.Lock()
+			delete(g.calls, key)
+			g.mutex.Unlock()
+			close(f.done)
+		}()
+	}
+	g.mutex.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
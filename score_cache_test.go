@@ -0,0 +1,192 @@
+package oclient
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	t     time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.t = c.t.Add(d)
+}
+
+// newCachingClient returns a client with a score cache talking to an oracle answering every service of every
+// destination with the score score(), the returned counter counts the requests of the oracle.
+func newCachingClient(t *testing.T, score func() float64) (*OracleClient, *fakeClock, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		var q server.ScoringQuery
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+		res := server.ScoringResponse{}
+		for rawDst, svcs := range q.Queries {
+			dst, _ := addr.IAFromString(rawDst)
+			scores := map[string]float64{}
+			for _, svc := range svcs {
+				scores[string(svc)] = score()
+			}
+			res[dst] = []server.FingerprintScores{{Fingerprint: "fp", Scores: scores}}
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithScoreCache(ScoreCacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Minute}))
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	c.cache.now = clock.now
+	return c, clock, &requests
+}
+
+func scoreQuery(dst addr.IA, svcs ...services.ServiceName) server.ScoringQuery {
+	return server.ScoringQuery{Queries: map[string][]services.ServiceName{dst.String(): svcs}}
+}
+
+func TestScoreCacheServesFreshScores(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	c, clock, requests := newCachingClient(t, func() float64 { return 42 })
+
+	for i := 0; i < 3; i++ {
+		res, err := c.FetchScores(scoreQuery(dst, "throughput"))
+		require.NoError(t, err)
+		assert.Equal(t, 42., res[dst][0].Scores["throughput"])
+		clock.advance(10 * time.Second)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	// scores of another service are fetched, throughput is not requested again
+	res, err := c.FetchScores(scoreQuery(dst, "throughput", "latency"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"throughput": 42, "latency": 42}, res[dst][0].Scores)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+}
+
+func TestScoreCacheRevalidatesStaleScores(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	var score int32 = 1
+	c, clock, requests := newCachingClient(t, func() float64 { return float64(atomic.LoadInt32(&score)) })
+
+	_, err := c.FetchScores(scoreQuery(dst, "throughput"))
+	require.NoError(t, err)
+
+	atomic.StoreInt32(&score, 2)
+	clock.advance(90 * time.Second)
+	res, err := c.FetchScores(scoreQuery(dst, "throughput"))
+	require.NoError(t, err)
+	assert.Equal(t, 1., res[dst][0].Scores["throughput"], "stale score is served")
+	assert.Eventually(t, func() bool {
+		res, err := c.FetchScores(scoreQuery(dst, "throughput"))
+		return err == nil && res[dst][0].Scores["throughput"] == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+	atomic.StoreInt32(&score, 3)
+	clock.advance(5 * time.Minute)
+	res, err = c.FetchScores(scoreQuery(dst, "throughput"))
+	require.NoError(t, err)
+	assert.Equal(t, 3., res[dst][0].Scores["throughput"], "expired score is fetched synchronously")
+}
+
+func TestScoreCacheCoalescesConcurrentFetches(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	release := make(chan struct{})
+	c, _, requests := newCachingClient(t, func() float64 {
+		<-release
+		return 1
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := c.FetchScoresContext(context.Background(), scoreQuery(dst, "throughput"))
+			assert.NoError(t, err)
+			assert.Len(t, res[dst], 1)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestScoreCacheFetchSurvivesCanceledCaller(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	release := make(chan struct{})
+	c, _, requests := newCachingClient(t, func() float64 {
+		<-release
+		return 1
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := c.FetchScoresContext(ctx, scoreQuery(dst, "throughput"))
+		first <- err
+	}()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(requests) == 1 }, time.Second, time.Millisecond)
+
+	// the caller which started the fetch gives up, the fetch completes for the callers joining it
+	cancel()
+	assert.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	res, err := c.FetchScores(scoreQuery(dst, "throughput"))
+	require.NoError(t, err)
+	assert.Equal(t, 1., res[dst][0].Scores["throughput"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestScoreCacheFetchKeepsCallerValues(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	ctx, cancel := context.WithCancel(trace.ContextWithSpanContext(context.Background(), span))
+	cancel()
+
+	type fetchContext struct {
+		span trace.SpanContext
+		err  error
+	}
+	fetched := make(chan fetchContext, 1)
+	release := make(chan struct{})
+	cache := newScoreCache(DefaultScoreCacheConfig, zap.NewNop().Sugar())
+	_, err := cache.fetch(ctx, scoreQuery(dst, "throughput"),
+		func(ctx context.Context, query server.ScoringQuery) (server.ScoringResponse, error) {
+			fetched <- fetchContext{span: trace.SpanContextFromContext(ctx), err: ctx.Err()}
+			<-release
+			return server.ScoringResponse{}, nil
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+	close(release)
+
+	// the fetch continues in the span of the caller, which gave up before
+	f := <-fetched
+	assert.Equal(t, span, f.span)
+	assert.NoError(t, f.err)
+}
@@ -0,0 +1,101 @@
+package oclient
+
+import (
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"sort"
+)
+
+// ScoreSet holds the scores of paths by destination, path fingerprint and service.
+// Lookups on a nil ScoreSet find nothing.
+type ScoreSet map[addr.IA]map[oracle.PathFingerprint]map[services.ServiceName]float64
+
+// NewScoreSet converts a response of the path oracle into a ScoreSet.
+func NewScoreSet(res server.ScoringResponse) ScoreSet {
+	set := make(ScoreSet, len(res))
+	for dst, fpScores := range res {
+		paths := make(map[oracle.PathFingerprint]map[services.ServiceName]float64, len(fpScores))
+		for _, fs := range fpScores {
+			scores := paths[fs.Fingerprint]
+			if scores == nil {
+				scores = make(map[services.ServiceName]float64, len(fs.Scores))
+				paths[fs.Fingerprint] = scores
+			}
+			for service, score := range fs.Scores {
+				scores[services.ServiceName(service)] = score
+			}
+		}
+		set[dst] = paths
+	}
+	return set
+}
+
+// Score returns the score of service for the path fp to dst.
+func (s ScoreSet) Score(dst addr.IA, fp oracle.PathFingerprint, service services.ServiceName) (float64, bool) {
+	score, ok := s[dst][fp][service]
+	return score, ok
+}
+
+// Path returns the scores of all services for the path fp to dst.
+func (s ScoreSet) Path(dst addr.IA, fp oracle.PathFingerprint) map[services.ServiceName]float64 {
+	return s[dst][fp]
+}
+
+// Service returns the scores of service for all scored paths to dst.
+func (s ScoreSet) Service(dst addr.IA, service services.ServiceName) map[oracle.PathFingerprint]float64 {
+	scores := make(map[oracle.PathFingerprint]float64)
+	for fp, pathScores := range s[dst] {
+		if score, ok := pathScores[service]; ok {
+			scores[fp] = score
+		}
+	}
+	return scores
+}
+
+// Best returns the path to dst with the highest score of service. Ties are broken by the fingerprint.
+func (s ScoreSet) Best(dst addr.IA, service services.ServiceName) (oracle.PathFingerprint, float64, bool) {
+	var (
+		best      oracle.PathFingerprint
+		bestScore float64
+		found     bool
+	)
+	for fp, score := range s.Service(dst, service) {
+		if !found || score > bestScore || (score == bestScore && fp < best) {
+			best, bestScore, found = fp, score, true
+		}
+	}
+	return best, bestScore, found
+}
+
+// Destinations returns all destinations with scored paths in ascending order.
+func (s ScoreSet) Destinations() []addr.IA {
+	dsts := make([]addr.IA, 0, len(s))
+	for dst, paths := range s {
+		if len(paths) > 0 {
+			dsts = append(dsts, dst)
+		}
+	}
+	sort.Slice(dsts, func(i, j int) bool {
+		if dsts[i].I != dsts[j].I {
+			return dsts[i].I < dsts[j].I
+		}
+		return dsts[i].A < dsts[j].A
+	})
+	return dsts
+}
+
+// Scores fetches the scores of the given services for all paths to each destination in a single request.
+func (c *OracleClient) Scores(ctx context.Context, queries map[addr.IA][]services.ServiceName) (ScoreSet, error) {
+	q := server.ScoringQuery{Queries: make(map[string][]services.ServiceName, len(queries))}
+	for dst, svcs := range queries {
+		q.Queries[dst.String()] = svcs
+	}
+	res, err := c.FetchScoresContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return NewScoreSet(res), nil
+}
@@ -0,0 +1,77 @@
+package oclient
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScoreSetLookups(t *testing.T) {
+	a, b := addr.IA{I: 1, A: 13}, addr.IA{I: 2, A: 1}
+	set := NewScoreSet(server.ScoringResponse{
+		b: {{Fingerprint: "b1", Scores: map[string]float64{"throughput": 5}}},
+		a: {
+			{Fingerprint: "a1", Scores: map[string]float64{"throughput": 10, "latency": 3}},
+			{Fingerprint: "a2", Scores: map[string]float64{"throughput": 20}},
+			{Fingerprint: "a3", Scores: map[string]float64{"throughput": 20}},
+		},
+	})
+
+	score, ok := set.Score(a, "a1", "latency")
+	assert.True(t, ok)
+	assert.Equal(t, 3., score)
+	_, ok = set.Score(a, "a2", "latency")
+	assert.False(t, ok)
+
+	assert.Equal(t, map[services.ServiceName]float64{"throughput": 10, "latency": 3}, set.Path(a, "a1"))
+	assert.Len(t, set.Service(a, "throughput"), 3)
+	assert.Len(t, set.Service(a, "latency"), 1)
+
+	fp, score, ok := set.Best(a, "throughput")
+	assert.True(t, ok)
+	assert.Equal(t, "a2", string(fp))
+	assert.Equal(t, 20., score)
+	_, _, ok = set.Best(b, "latency")
+	assert.False(t, ok)
+
+	assert.Equal(t, []addr.IA{a, b}, set.Destinations())
+
+	var empty ScoreSet
+	_, ok = empty.Score(a, "a1", "throughput")
+	assert.False(t, ok)
+	assert.Empty(t, empty.Service(a, "throughput"))
+}
+
+func TestScoresQueriesSeveralDestinations(t *testing.T) {
+	a, b := addr.IA{I: 1, A: 13}, addr.IA{I: 2, A: 1}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q server.ScoringQuery
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+		assert.Equal(t, []services.ServiceName{"throughput", "latency"}, q.Queries[a.String()])
+		assert.Equal(t, []services.ServiceName{"loss"}, q.Queries[b.String()])
+		json.NewEncoder(w).Encode(server.ScoringResponse{
+			a: {{Fingerprint: "a1", Scores: map[string]float64{"throughput": 10, "latency": 3}}},
+			b: {{Fingerprint: "b1", Scores: map[string]float64{"loss": 0.1}}},
+		})
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	set, err := c.Scores(context.Background(), map[addr.IA][]services.ServiceName{
+		a: {"throughput", "latency"},
+		b: {"loss"},
+	})
+	require.NoError(t, err)
+	score, ok := set.Score(b, "b1", "loss")
+	assert.True(t, ok)
+	assert.Equal(t, 0.1, score)
+	assert.Len(t, set.Path(a, "a1"), 2)
+}
@@ -1,11 +1,13 @@
 package selectors
 
 import (
+	"context"
+	"errors"
 	oracle "github.com/clemens97/scion-path-oracle"
-	"github.com/clemens97/scion-path-oracle/server"
 	"github.com/clemens97/scion-path-oracle/services"
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
 	"github.com/scionproto/scion/go/lib/addr"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"oclient"
 	"sort"
@@ -13,6 +15,8 @@ import (
 	"time"
 )
 
+const throughputService services.ServiceName = "throughput"
+
 // ThroughputPathSelector selects the path with the best throughput according to a path oracle
 type ThroughputPathSelector struct {
 	mutex  sync.Mutex
@@ -20,16 +24,19 @@ type ThroughputPathSelector struct {
 	pc     chan<- *pan.Path
 
 	config       OracleSelectorConfig
-	oracleClient oclient.OracleClient
-	oracleTicker *time.Ticker
+	oracleClient *oclient.OracleClient
 	oracleScores map[oracle.PathFingerprint]float64
+	// ctx is cancelled when the selector is closed to abort pending oracle requests.
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	paths    []*pan.Path
 	remoteIA addr.IA
 }
 
-func NewThroughputPathSelector(config OracleSelectorConfig, logger *zap.SugaredLogger) *ThroughputPathSelector {
-	return &ThroughputPathSelector{oracleClient: oclient.NewOracleClient(), logger: logger, config: config}
+func NewThroughputPathSelector(client *oclient.OracleClient, config OracleSelectorConfig, logger *zap.SugaredLogger) *ThroughputPathSelector {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ThroughputPathSelector{oracleClient: client, logger: logger, config: config, ctx: ctx, cancel: cancel}
 }
 
 func (s *ThroughputPathSelector) Path() *pan.Path {
@@ -50,11 +57,18 @@ func (s *ThroughputPathSelector) Initialize(local, remote pan.UDPAddr, paths []*
 
 	s.logger.Debugw("Initialize", "remote", remote, "local", local)
 	s.remoteIA = addr.IA{I: remote.IA.I, A: remote.IA.A}
+	spanCtx, span := startSpan(s.ctx, "ThroughputPathSelector.Initialize",
+		oclient.DstIAKey.String(s.remoteIA.String()), oclient.PathCountKey.Int(len(paths)))
+	defer span.End()
 
 	s.paths = paths
-	scores, _ := s.refreshOracleScores()
+	// Initialize blocks dialing, hence the oracle request is bounded by FetchScoresTimeout
+	ctx, cancel := s.fetchContext(spanCtx)
+	scores, _ := s.refreshOracleScores(ctx)
+	cancel()
 	s.oracleScores = scores
 	s.rank()
+	s.traceSelected(span, true)
 	if len(s.paths) > 0 {
 		s.logger.Infow("selected initial path for con", "fp", s.paths[0].Fingerprint)
 		// consumer (tracer) not ready yet, so sent first path async
@@ -63,41 +77,89 @@ func (s *ThroughputPathSelector) Initialize(local, remote pan.UDPAddr, paths []*
 		}()
 	}
 
+	switch {
+	case s.config.FetchScoresInterval <= 0:
+		// scores are fetched only once
+	case s.config.DisableSubscription:
+		go s.poll()
+	default:
+		go s.subscribe()
+	}
+}
+
+// subscribe applies score updates pushed by the oracle, falling back to polling if the oracle does not offer
+// subscriptions.
+func (s *ThroughputPathSelector) subscribe() {
+	sub, err := s.oracleClient.Subscribe(s.ctx, map[addr.IA][]services.ServiceName{s.remoteIA: {throughputService}},
+		oclient.DefaultSubscriptionConfig)
+	if err != nil {
+		if s.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, oclient.ErrSubscriptionUnsupported) {
+			s.logger.Debugw("oracle does not push scores, polling instead")
+		} else {
+			s.logger.Infow("error subscribing to oracle scores, polling instead", "error", err)
+		}
+		s.poll()
+		return
+	}
+	defer sub.Close()
+
+	for scoreSet := range sub.Updates() {
+		scores := scoreSet.Service(s.remoteIA, throughputService)
+		s.logger.Debugw("received scores from oracle", "scores", scores)
+		s.applyScores(scores, "changed path on oracle score update")
+	}
+}
+
+// poll refetches the scores every FetchScoresInterval until the selector is closed.
+func (s *ThroughputPathSelector) poll() {
 	if s.config.FetchScoresInterval <= 0 {
 		return
 	}
+	ticker := time.NewTicker(s.config.FetchScoresInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			n := len(s.paths)
+			s.mutex.Unlock()
+			if n < 2 {
+				// no paths to decide between, no need to fetch oracle score
+				continue
+			}
 
-	s.oracleTicker = time.NewTicker(s.config.FetchScoresInterval)
-	go func() {
-		for {
-			select {
-			case <-s.oracleTicker.C:
-				if len(s.paths) < 2 {
-					// no paths to decide between, no need to fetch oracle score
-					return
-				}
-
-				scs, err := s.refreshOracleScores()
-				if err != nil {
-					return
-				}
-
-				s.oracleScores = scs
-				s.mutex.Lock()
-				if len(s.paths) == 0 {
-					return
-				}
-				curBestFp := s.paths[0].Fingerprint
-				s.rank()
-				// path changed
-				if newBestFp := s.paths[0].Fingerprint; newBestFp != curBestFp {
-					s.logger.Infow("changed path on new oracle scores", "previousFp", curBestFp, "newFp", s.paths[0].Fingerprint)
-					s.pc <- s.paths[0]
-				}
-				s.mutex.Unlock()
+			ctx, cancel := s.fetchContext(s.ctx)
+			scores, err := s.refreshOracleScores(ctx)
+			cancel()
+			if err != nil {
+				continue
 			}
+			s.applyScores(scores, "changed path on new oracle scores")
 		}
-	}()
+	}
+}
+
+// applyScores reranks the paths by scores and publishes the best path if it changed.
+func (s *ThroughputPathSelector) applyScores(scores map[oracle.PathFingerprint]float64, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.oracleScores = scores
+	if len(s.paths) == 0 {
+		return
+	}
+	curBestFp := s.paths[0].Fingerprint
+	s.rank()
+	// path changed
+	if newBestFp := s.paths[0].Fingerprint; newBestFp != curBestFp {
+		s.logger.Infow(reason, "previousFp", curBestFp, "newFp", newBestFp)
+		s.pc <- s.paths[0]
+	}
 }
 
 func (s *ThroughputPathSelector) rank() {
@@ -118,22 +180,39 @@ func (s *ThroughputPathSelector) rank() {
 	})
 }
 
-func (s *ThroughputPathSelector) refreshOracleScores() (map[oracle.PathFingerprint]float64, error) {
-	scores := make(map[oracle.PathFingerprint]float64)
+// fetchContext bounds a single oracle request by FetchScoresTimeout and the lifetime of the selector. parent is
+// derived from the selector's context and carries the span the request belongs to.
+func (s *ThroughputPathSelector) fetchContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if s.config.FetchScoresTimeout > 0 {
+		return context.WithTimeout(parent, s.config.FetchScoresTimeout)
+	}
+	return context.WithCancel(parent)
+}
 
-	q := map[string][]services.ServiceName{s.remoteIA.String(): {"throughput"}}
-	scoringRes, err := s.oracleClient.FetchScores(server.ScoringQuery{Queries: q})
-	if err != nil {
-		s.logger.Errorw("error fetching scores from oracle", "error", err)
-		return scores, err
+// traceSelected records the selected path and its oracle score on span.
+func (s *ThroughputPathSelector) traceSelected(span trace.Span, changed bool) {
+	if len(s.paths) == 0 {
+		traceSelected(span, nil, changed)
+		return
 	}
+	traceSelected(span, s.paths[0], changed)
+	if score, ok := s.oracleScores[oracle.PathFingerprint(s.paths[0].Fingerprint)]; ok {
+		span.SetAttributes(oclient.ScoreKey.Float64(score))
+	}
+}
 
-	for _, e := range scoringRes[s.remoteIA] {
-		if bwScore, ok := e.Scores["throughput"]; ok {
-			scores[e.Fingerprint] = bwScore
+func (s *ThroughputPathSelector) refreshOracleScores(ctx context.Context) (map[oracle.PathFingerprint]float64, error) {
+	scoreSet, err := s.oracleClient.Scores(ctx, map[addr.IA][]services.ServiceName{s.remoteIA: {throughputService}})
+	if err != nil {
+		if s.oracleClient.Degraded() {
+			s.logger.Warnw("oracle degraded, ranking paths by hop count", "error", err)
+		} else {
+			s.logger.Errorw("error fetching scores from oracle", "error", err)
 		}
+		return make(map[oracle.PathFingerprint]float64), err
 	}
 
+	scores := scoreSet.Service(s.remoteIA, throughputService)
 	s.logger.Infow("successfully fetched scores from oracle", "scores", scores)
 	return scores, nil
 }
@@ -142,6 +221,8 @@ func (s *ThroughputPathSelector) Refresh(paths []*pan.Path) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.logger.Debugw("Refresh")
+	_, span := startSpan(s.ctx, "ThroughputPathSelector.Refresh", oclient.PathCountKey.Int(len(paths)))
+	defer span.End()
 
 	if len(paths) == 0 && len(s.paths) == 0 {
 		// no new paths submitted and no paths prior to refresh
@@ -150,6 +231,7 @@ func (s *ThroughputPathSelector) Refresh(paths []*pan.Path) {
 	if len(paths) == 0 && len(s.paths) >= 1 {
 		// no paths submitted but there were paths prior to refresh
 		s.paths = paths
+		s.traceSelected(span, true)
 		s.pc <- nil
 		return
 	}
@@ -159,6 +241,7 @@ func (s *ThroughputPathSelector) Refresh(paths []*pan.Path) {
 	s.paths = paths
 	s.rank()
 	newBestFp := s.paths[0].Fingerprint
+	s.traceSelected(span, bestFp != newBestFp)
 	if bestFp != newBestFp {
 		s.logger.Infow("changed path on refresh", "previousFp", bestFp, "newFp", newBestFp)
 		s.pc <- s.paths[0]
@@ -169,6 +252,8 @@ func (s *ThroughputPathSelector) PathDown(fp pan.PathFingerprint, pi pan.PathInt
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.logger.Debugw("PathDown", "fingerprint", fp, "interface", pi)
+	_, span := startSpan(s.ctx, "ThroughputPathSelector.PathDown", pathDownAttributes(fp, pi)...)
+	defer span.End()
 
 	if len(s.paths) == 0 {
 		return
@@ -183,6 +268,7 @@ func (s *ThroughputPathSelector) PathDown(fp pan.PathFingerprint, pi pan.PathInt
 		remaining = append(remaining, p)
 	}
 	s.paths = remaining
+	s.traceSelected(span, bestFp != s.paths[0].Fingerprint)
 	if bestFp != s.paths[0].Fingerprint {
 		s.logger.Infow("changed path on pathdown", "previousFp", bestFp, "newFp", s.paths[0].Fingerprint)
 		s.pc <- s.paths[0]
@@ -194,9 +280,7 @@ func (s *ThroughputPathSelector) Close() error {
 	defer s.mutex.Unlock()
 
 	s.logger.Debugw("Close")
-	if s.oracleTicker != nil {
-		s.oracleTicker.Stop()
-	}
+	s.cancel()
 	return nil
 }
 
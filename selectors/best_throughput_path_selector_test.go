@@ -3,8 +3,15 @@ package selectors
 import (
 	oracle "github.com/clemens97/scion-path-oracle"
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"github.com/scionproto/scion/go/lib/addr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"oclient"
+	"oclient/oracletest"
 	"testing"
+	"time"
 )
 
 func TestRankScores(t *testing.T) {
@@ -26,3 +33,52 @@ func TestRankScores(t *testing.T) {
 	assert.Equal(t, pan.PathFingerprint("a"), selector.paths[2].Fingerprint)
 	assert.Equal(t, pan.PathFingerprint("b"), selector.paths[3].Fingerprint)
 }
+
+func TestSelectorFollowsPushedScores(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	srv := oracletest.NewServer()
+	defer srv.Close()
+	srv.SetScore(dst, "a", "throughput", 2)
+	srv.SetScore(dst, "b", "throughput", 1)
+
+	client, err := oclient.NewOracleClient(oclient.WithBaseURL(srv.URL), oclient.WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	selector := NewThroughputPathSelector(client, OracleSelectorConfig{FetchScoresInterval: time.Hour},
+		zap.NewNop().Sugar())
+	pc := make(chan *pan.Path, 4)
+	selector.SetPathChan(pc)
+
+	paths := []*pan.Path{
+		{Fingerprint: "a", Metadata: &pan.PathMetadata{Interfaces: []pan.PathInterface{{}}}},
+		{Fingerprint: "b", Metadata: &pan.PathMetadata{Interfaces: []pan.PathInterface{{}}}},
+	}
+	selector.Initialize(pan.UDPAddr{}, pan.UDPAddr{IA: pan.IA{I: 1, A: 13}}, paths)
+	defer selector.Close()
+	assert.Equal(t, pan.PathFingerprint("a"), (<-pc).Fingerprint)
+
+	assert.Eventually(t, func() bool { return srv.Subscribers() == 1 }, time.Second, 5*time.Millisecond)
+	srv.SetScore(dst, "b", "throughput", 5)
+	select {
+	case p := <-pc:
+		assert.Equal(t, pan.PathFingerprint("b"), p.Fingerprint)
+	case <-time.After(time.Second):
+		t.Fatal("selector did not switch to the path with the better pushed score")
+	}
+	assert.Equal(t, pan.PathFingerprint("b"), selector.Path().Fingerprint)
+}
+
+func TestSelectorFetchesOnceWithoutInterval(t *testing.T) {
+	srv := oracletest.NewServer()
+	defer srv.Close()
+	srv.SetScore(addr.IA{I: 1, A: 13}, "a", "throughput", 1)
+
+	client, err := oclient.NewOracleClient(oclient.WithBaseURL(srv.URL), oclient.WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	selector := NewThroughputPathSelector(client, OracleSelectorConfig{}, zap.NewNop().Sugar())
+	selector.SetPathChan(make(chan *pan.Path, 1))
+	paths := []*pan.Path{{Fingerprint: "a", Metadata: &pan.PathMetadata{Interfaces: []pan.PathInterface{{}}}}}
+	selector.Initialize(pan.UDPAddr{}, pan.UDPAddr{IA: pan.IA{I: 1, A: 13}}, paths)
+	defer selector.Close()
+
+	assert.Never(t, func() bool { return srv.Subscribers() > 0 }, 100*time.Millisecond, 5*time.Millisecond)
+}
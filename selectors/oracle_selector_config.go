@@ -5,6 +5,12 @@ import "time"
 type OracleSelectorConfig struct {
 	// FetchScoresInterval is the time interval after Path Scorings are fetched from the Path Oracle
 	// and our current path choice is reevaluated.
-	// To fetch scores only once (on initialisation) specify 0.
+	// To fetch scores only once (on initialisation) specify 0, the selector then neither polls nor subscribes.
 	FetchScoresInterval time.Duration
+	// FetchScoresTimeout bounds a single request for Path Scorings. As the initial request blocks dialing
+	// the connection, this is the oracle budget of a dial. 0 waits for the Path Oracle indefinitely.
+	FetchScoresTimeout time.Duration
+	// DisableSubscription polls Path Scorings every FetchScoresInterval, even if the Path Oracle is able to push
+	// score updates. By default, the selector subscribes to updates and only polls if subscribing fails.
+	DisableSubscription bool
 }
@@ -0,0 +1,85 @@
+package selectors
+
+import (
+	"context"
+	"fmt"
+	"github.com/netsec-ethz/scion-apps/pkg/pan"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"oclient"
+)
+
+const tracerName = "oclient/selectors"
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// traceSelected records the path selected at the end of span.
+func traceSelected(span trace.Span, path *pan.Path, changed bool) {
+	span.SetAttributes(attribute.Bool("path.changed", changed))
+	if path != nil {
+		span.SetAttributes(oclient.FingerprintKey.String(string(path.Fingerprint)))
+	}
+}
+
+// TracingSelector records a span for every path event of a selector, carrying the path selected afterwards.
+// Selectors fetching scores from a path oracle, e.g. ThroughputPathSelector, trace themselves.
+type TracingSelector struct {
+	pan.Selector
+	name string
+}
+
+// NewTracingSelector traces selector, naming its spans after name.
+func NewTracingSelector(name string, selector pan.Selector) *TracingSelector {
+	return &TracingSelector{Selector: selector, name: name}
+}
+
+// SetPathChan forwards pc to the traced selector if it publishes path updates.
+func (t *TracingSelector) SetPathChan(pc chan<- *pan.Path) {
+	if pb, ok := t.Selector.(oclient.PathPublisher); ok {
+		pb.SetPathChan(pc)
+	}
+}
+
+func (t *TracingSelector) Initialize(local, remote pan.UDPAddr, paths []*pan.Path) {
+	_, span := startSpan(context.Background(), t.name+".Initialize",
+		oclient.DstIAKey.String(remote.IA.String()), oclient.PathCountKey.Int(len(paths)))
+	defer span.End()
+	t.Selector.Initialize(local, remote, paths)
+	traceSelected(span, t.Selector.Path(), true)
+}
+
+func (t *TracingSelector) Refresh(paths []*pan.Path) {
+	_, span := startSpan(context.Background(), t.name+".Refresh", oclient.PathCountKey.Int(len(paths)))
+	defer span.End()
+	before := t.Selector.Path()
+	t.Selector.Refresh(paths)
+	after := t.Selector.Path()
+	traceSelected(span, after, fingerprint(before) != fingerprint(after))
+}
+
+func (t *TracingSelector) PathDown(fp pan.PathFingerprint, pi pan.PathInterface) {
+	_, span := startSpan(context.Background(), t.name+".PathDown", pathDownAttributes(fp, pi)...)
+	defer span.End()
+	before := t.Selector.Path()
+	t.Selector.PathDown(fp, pi)
+	after := t.Selector.Path()
+	traceSelected(span, after, fingerprint(before) != fingerprint(after))
+}
+
+// pathDownAttributes describe the path or interface reported down.
+func pathDownAttributes(fp pan.PathFingerprint, pi pan.PathInterface) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("path.down.fingerprint", string(fp)),
+		attribute.String("path.down.interface", fmt.Sprintf("%s#%d", pi.IA, pi.IfID)),
+	}
+}
+
+func fingerprint(path *pan.Path) pan.PathFingerprint {
+	if path == nil {
+		return ""
+	}
+	return path.Fingerprint
+}
@@ -0,0 +1,233 @@
+// Package signing adds proof of origin to oracle reports. Reports are signed using Ed25519, the signature and the
+// ID of the signing key are embedded in the metadata properties of the report.
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"oclient"
+)
+
+const (
+	// SignatureProperty is the metadata property holding the base64 encoded signature of a report.
+	SignatureProperty = "signature"
+	// KeyIDProperty is the metadata property holding the ID of the key a report was signed with.
+	KeyIDProperty = "signature_key_id"
+)
+
+var (
+	// ErrUnsigned is returned when verifying a report without signature.
+	ErrUnsigned = errors.New("report is not signed")
+	// ErrUnknownKey is returned when verifying a report signed by a key which is not trusted.
+	ErrUnknownKey = errors.New("report is signed by an unknown key")
+	// ErrBadSignature is returned when the signature of a report does not match its content.
+	ErrBadSignature = errors.New("report signature is invalid")
+)
+
+// KeyID derives the default ID of a public key, the hex encoded first 8 bytes of its SHA-256 hash.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer signs reports using an Ed25519 private key.
+type Signer struct {
+	KeyID string
+	Key   ed25519.PrivateKey
+}
+
+// NewSigner creates a Signer for key, identified by the KeyID of its public key.
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{KeyID: KeyID(key.Public().(ed25519.PublicKey)), Key: key}
+}
+
+// LoadSigner creates a Signer using the PEM encoded (PKCS #8) private key in file, as generated by
+// `openssl genpkey -algorithm ed25519`. keyID defaults to the KeyID of the public key, if empty.
+func LoadSigner(file, keyID string) (*Signer, error) {
+	key, err := LoadPrivateKey(file)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSigner(key)
+	if keyID != "" {
+		s.KeyID = keyID
+	}
+	return s, nil
+}
+
+// Sign returns a copy of report carrying the signature in its metadata properties.
+func (s *Signer) Sign(report oracle.Report) (oracle.Report, error) {
+	payload, err := signedPayload(report)
+	if err != nil {
+		return report, err
+	}
+
+	props := make(oracle.MetadataProperties, len(report.Metadata.Properties)+2)
+	for k, v := range report.Metadata.Properties {
+		props[k] = v
+	}
+	props[KeyIDProperty] = s.KeyID
+	props[SignatureProperty] = base64.StdEncoding.EncodeToString(ed25519.Sign(s.Key, payload))
+	report.Metadata.Properties = props
+	return report, nil
+}
+
+// Verifier checks the signatures of reports against a set of trusted public keys.
+type Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+func NewVerifier() *Verifier {
+	return &Verifier{keys: make(map[string]ed25519.PublicKey)}
+}
+
+// Trust accepts reports signed by pub, identified by keyID.
+func (v *Verifier) Trust(keyID string, pub ed25519.PublicKey) {
+	v.keys[keyID] = pub
+}
+
+// Verify returns nil if report was signed by a trusted key and was not modified since.
+func (v *Verifier) Verify(report oracle.Report) error {
+	rawSig, ok := report.Metadata.Properties[SignatureProperty].(string)
+	if !ok {
+		return ErrUnsigned
+	}
+	keyID, _ := report.Metadata.Properties[KeyIDProperty].(string)
+	pub, ok := v.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(rawSig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	payload, err := signedPayload(report)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, payload, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// payload is the canonical representation of a report covered by its signature. It includes the path parameters
+// of the report, so a signed report cannot be replayed for a different path. The keys of maps are sorted by
+// encoding/json, hence the representation survives a round-trip through JSON.
+type payload struct {
+	DstIA       addr.IA                    `json:"dst_ia"`
+	PathFp      oracle.PathFingerprint     `json:"path_fp"`
+	Application string                     `json:"application"`
+	Duration    float64                    `json:"duration"`
+	Metadata    oracle.MetadataProperties  `json:"meta"`
+	Properties  oracle.MonitoredProperties `json:"stats"`
+}
+
+func signedPayload(report oracle.Report) ([]byte, error) {
+	meta := make(oracle.MetadataProperties, len(report.Metadata.Properties))
+	for k, v := range report.Metadata.Properties {
+		if k != SignatureProperty && k != KeyIDProperty {
+			meta[k] = v
+		}
+	}
+	return json.Marshal(payload{
+		DstIA:       report.DstIA,
+		PathFp:      report.PathFp,
+		Application: report.Metadata.Application,
+		Duration:    report.Metadata.Duration,
+		Metadata:    meta,
+		Properties:  report.Properties,
+	})
+}
+
+// Reporter signs all reports before passing them on to the next StatsReporter.
+type Reporter struct {
+	signer *Signer
+	next   oclient.StatsReporter
+}
+
+func NewReporter(next oclient.StatsReporter, signer *Signer) *Reporter {
+	return &Reporter{signer: signer, next: next}
+}
+
+func (r *Reporter) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	signed, err := r.signer.Sign(report)
+	if err != nil {
+		return fmt.Errorf("signing report: %w", err)
+	}
+	return r.next.ReportStatsContext(ctx, signed)
+}
+
+// ReportStatsBatchContext signs all reports and passes them on as a batch. It returns oclient.ErrBatchUnsupported
+// if the next StatsReporter does not support batches.
+func (r *Reporter) ReportStatsBatchContext(ctx context.Context, reports []oracle.Report) error {
+	batch, ok := r.next.(oclient.BatchStatsReporter)
+	if !ok {
+		return oclient.ErrBatchUnsupported
+	}
+	signed := make([]oracle.Report, len(reports))
+	for i, report := range reports {
+		var err error
+		if signed[i], err = r.signer.Sign(report); err != nil {
+			return fmt.Errorf("signing report: %w", err)
+		}
+	}
+	return batch.ReportStatsBatchContext(ctx, signed)
+}
+
+// LoadPrivateKey reads a PEM encoded (PKCS #8) Ed25519 private key from file.
+func LoadPrivateKey(file string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(file, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key %s: %w", file, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an Ed25519 private key", file)
+	}
+	return edKey, nil
+}
+
+// LoadPublicKey reads a PEM encoded (PKIX) Ed25519 public key from file.
+func LoadPublicKey(file string) (ed25519.PublicKey, error) {
+	der, err := readPEM(file, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key %s: %w", file, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an Ed25519 public key", file)
+	}
+	return edKey, nil
+}
+
+func readPEM(file, blockType string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("no PEM block of type %q found in %s", blockType, file)
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,107 @@
+package signing
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"oclient"
+	"oclient/oracletest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testReport() oracle.Report {
+	return oracle.Report{
+		Metadata: oracle.Metadata{
+			Application: "quic_sender",
+			Duration:    5,
+			Properties:  oracle.MetadataProperties{"protocols": []string{"SCION", "UDP", "QUIC"}},
+		},
+		Properties: oracle.MonitoredProperties{"throughput": 1250000.5},
+		DstIA:      addr.IA{I: 1, A: 13},
+		PathFp:     "a b",
+	}
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return pub, priv
+}
+
+func TestSignAndVerify(t *testing.T) {
+	pub, priv := newKey(t)
+	signer := NewSigner(priv)
+	verifier := NewVerifier()
+	verifier.Trust(signer.KeyID, pub)
+
+	report := testReport()
+	signed, err := signer.Sign(report)
+	require.NoError(t, err)
+	assert.NotContains(t, report.Metadata.Properties, SignatureProperty, "original report must not be modified")
+	assert.NoError(t, verifier.Verify(signed))
+
+	assert.True(t, errors.Is(verifier.Verify(report), ErrUnsigned))
+
+	tampered := signed
+	tampered.Properties = oracle.MonitoredProperties{"throughput": 1e12}
+	assert.True(t, errors.Is(verifier.Verify(tampered), ErrBadSignature))
+
+	moved := signed
+	moved.PathFp = "other"
+	assert.True(t, errors.Is(verifier.Verify(moved), ErrBadSignature))
+
+	_, otherPriv := newKey(t)
+	foreign, err := NewSigner(otherPriv).Sign(report)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(verifier.Verify(foreign), ErrUnknownKey))
+}
+
+func TestSignatureSurvivesSubmission(t *testing.T) {
+	pub, priv := newKey(t)
+	signer := NewSigner(priv)
+	verifier := NewVerifier()
+	verifier.Trust(signer.KeyID, pub)
+
+	srv := oracletest.NewServer()
+	defer srv.Close()
+	c, err := oclient.NewOracleClient(oclient.WithBaseURL(srv.URL), oclient.WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+
+	require.NoError(t, NewReporter(c, signer).ReportStatsContext(context.Background(), testReport()))
+	require.Len(t, srv.Reports(), 1)
+	assert.NoError(t, verifier.Verify(srv.Reports()[0]))
+}
+
+func TestLoadKeys(t *testing.T) {
+	pub, priv := newKey(t)
+	dir := t.TempDir()
+
+	rawPriv, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	privFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rawPriv}), 0600))
+	rawPub, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	pubFile := filepath.Join(dir, "key.pub")
+	require.NoError(t, os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rawPub}), 0600))
+
+	signer, err := LoadSigner(privFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(pub), signer.KeyID)
+	loadedPub, err := LoadPublicKey(pubFile)
+	require.NoError(t, err)
+	assert.Equal(t, pub, loadedPub)
+
+	_, err = LoadPublicKey(privFile)
+	assert.Error(t, err)
+}
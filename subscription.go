@@ -0,0 +1,291 @@
+package oclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/clemens97/scion-path-oracle/services"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	// scoresEvent is the type of server-sent events carrying a server.ScoringResponse.
+	scoresEvent = "scores"
+	// maxEventSize limits the size of a single server-sent event.
+	maxEventSize = 4 << 20
+)
+
+type SubscriptionConfig struct {
+	// ReconnectDelay is the initial wait before reconnecting a broken subscription. It is doubled after every
+	// failed attempt up to MaxReconnectDelay.
+	ReconnectDelay    time.Duration
+	MaxReconnectDelay time.Duration
+}
+
+// DefaultSubscriptionConfig reconnects after a second, backing off to a minute.
+var DefaultSubscriptionConfig = SubscriptionConfig{
+	ReconnectDelay:    time.Second,
+	MaxReconnectDelay: time.Minute,
+}
+
+// Subscription receives score updates pushed by the path oracle as server-sent events.
+// Broken connections are re-established until the subscription is closed.
+type Subscription struct {
+	client *OracleClient
+	config SubscriptionConfig
+	query  []byte
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	updates chan ScoreSet
+	done    chan struct{}
+}
+
+// Subscribe watches the scores of the given services for all paths to each destination. The current scores are
+// delivered right away, further updates whenever the path oracle pushes them. Subscribe returns
+// ErrSubscriptionUnsupported if the path oracle does not offer subscriptions.
+// The subscription ends when ctx is done or it is closed.
+func (c *OracleClient) Subscribe(ctx context.Context, queries map[addr.IA][]services.ServiceName,
+	config SubscriptionConfig) (*Subscription, error) {
+
+	q := server.ScoringQuery{Queries: make(map[string][]services.ServiceName, len(queries))}
+	for dst, svcs := range queries {
+		q.Queries[dst.String()] = svcs
+	}
+	body, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = DefaultSubscriptionConfig.ReconnectDelay
+	}
+	if config.MaxReconnectDelay < config.ReconnectDelay {
+		config.MaxReconnectDelay = config.ReconnectDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		client:  c,
+		config:  config,
+		query:   body,
+		ctx:     ctx,
+		cancel:  cancel,
+		updates: make(chan ScoreSet, 1),
+		done:    make(chan struct{}),
+	}
+	res, err := s.open()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go s.run(res)
+	return s, nil
+}
+
+// Updates delivers the latest scores. Updates not consumed in time are replaced by newer ones.
+// The channel is closed when the subscription ends.
+func (s *Subscription) Updates() <-chan ScoreSet {
+	return s.updates
+}
+
+// Close ends the subscription and waits until its connection is closed.
+func (s *Subscription) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// open establishes the event stream with the preferred endpoint offering subscriptions.
+func (s *Subscription) open() (*http.Response, error) {
+	eps := s.client.candidates()
+	if len(eps) == 0 {
+		return nil, ErrNoOracle
+	}
+	var err error
+	unsupported := true
+	for _, ep := range eps {
+		var res *http.Response
+		res, err = s.openEndpoint(ep)
+		if err == nil {
+			return res, nil
+		}
+		if s.ctx.Err() != nil {
+			return nil, err
+		}
+		if !errors.Is(err, ErrSubscriptionUnsupported) {
+			unsupported = false
+		}
+	}
+	if unsupported {
+		return nil, ErrSubscriptionUnsupported
+	}
+	return nil, err
+}
+
+func (s *Subscription) openEndpoint(ep *endpoint) (*http.Response, error) {
+	c := s.client
+	paths, err := c.paths(s.ctx, ep)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest(s.ctx, http.MethodPost, ep.url(paths.Subscribe),
+		&payload{body: s.query, contentType: jsonContentType})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", eventStreamContentType)
+
+	if !ep.breaker.allow() {
+		c.metrics.circuitOpen(ep, opSubscribe)
+		return nil, ErrCircuitOpen
+	}
+	c.logger.Debugw("subscribing to score updates", "url", req.URL)
+	res, err := c.send(ep, opSubscribe, ep.streamc, req, len(s.query))
+	if err != nil {
+		if s.ctx.Err() != nil {
+			ep.breaker.release()
+		} else {
+			ep.breaker.failure()
+		}
+		return nil, &ConnectionError{Err: err}
+	}
+	if res.StatusCode >= 500 {
+		ep.breaker.failure()
+	} else {
+		ep.breaker.success()
+	}
+
+	if err := checkResponse(res, ErrSubscriptionUnsupported); err != nil {
+		res.Body.Close()
+		var resErr *ResponseError
+		if errors.As(err, &resErr) &&
+			(resErr.StatusCode == http.StatusNotFound || resErr.StatusCode == http.StatusMethodNotAllowed) {
+			return nil, ErrSubscriptionUnsupported
+		}
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mediaType != eventStreamContentType {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: unexpected content type %q", ErrSubscriptionUnsupported, mediaType)
+	}
+	return res, nil
+}
+
+func (s *Subscription) run(res *http.Response) {
+	defer close(s.done)
+	defer close(s.updates)
+	logger := s.client.logger
+
+	delay := s.config.ReconnectDelay
+	for {
+		received, err := s.consume(res.Body)
+		res.Body.Close()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if received {
+			delay = s.config.ReconnectDelay
+		}
+		logger.Infow("score subscription interrupted, reconnecting", "error", err, "delay", delay)
+
+		for {
+			if sleep(s.ctx, delay) != nil {
+				return
+			}
+			if delay *= 2; delay > s.config.MaxReconnectDelay {
+				delay = s.config.MaxReconnectDelay
+			}
+			res, err = s.open()
+			if err == nil {
+				break
+			}
+			logger.Infow("error reconnecting score subscription", "error", err, "delay", delay)
+		}
+	}
+}
+
+// consume delivers the scores of all events read from r. It reports whether any scores were received.
+func (s *Subscription) consume(r io.Reader) (bool, error) {
+	received := false
+	err := readEvents(r, func(event string, data []byte) {
+		if event != scoresEvent && event != "" {
+			return
+		}
+		var res server.ScoringResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			s.client.logger.Infow("ignoring malformed score update", "error", err)
+			return
+		}
+		received = true
+		s.deliver(NewScoreSet(res))
+	})
+	return received, err
+}
+
+// deliver replaces an unconsumed update by set.
+func (s *Subscription) deliver(set ScoreSet) {
+	for {
+		select {
+		case s.updates <- set:
+			return
+		default:
+		}
+		select {
+		case <-s.updates:
+		default:
+		}
+	}
+}
+
+// readEvents parses a stream of server-sent events, calling fn for every event carrying data.
+// It returns the error ending the stream, io.EOF if the stream ended regularly.
+func readEvents(r io.Reader, fn func(event string, data []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+
+	var (
+		event string
+		data  bytes.Buffer
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 {
+				fn(event, data.Bytes())
+			}
+			event = ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// comment, e.g. a keep-alive
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
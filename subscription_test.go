@@ -0,0 +1,79 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadEvents(t *testing.T) {
+	stream := ": keep-alive\n\nevent: scores\ndata: {\"a\":\ndata: 1}\n\nevent: other\ndata:x\n\ndata: plain\n\n"
+	var events, data []string
+	err := readEvents(strings.NewReader(stream), func(event string, d []byte) {
+		events = append(events, event)
+		data = append(data, string(d))
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []string{"scores", "other", ""}, events)
+	assert.Equal(t, []string{"{\"a\":\n1}", "x", "plain"}, data)
+}
+
+func TestSubscribeUnsupported(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	_, err = c.Subscribe(context.Background(), nil, DefaultSubscriptionConfig)
+	assert.True(t, errors.Is(err, ErrSubscriptionUnsupported))
+}
+
+func TestSubscriptionReconnects(t *testing.T) {
+	dst := addr.IA{I: 1, A: 13}
+	var connections int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, defaultPaths.Subscribe, r.URL.Path)
+		assert.Equal(t, eventStreamContentType, r.Header.Get("Accept"))
+		n := atomic.AddInt32(&connections, 1)
+		w.Header().Set("Content-Type", eventStreamContentType)
+		fmt.Fprintf(w, "event: scores\ndata: {\"%s\":[{\"fingerprint\":\"fp\",\"scores\":{\"throughput\":%d}}]}\n\n", dst, n)
+		w.(http.Flusher).Flush()
+		if n > 1 {
+			// keep the second connection open until the client goes away
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	sub, err := c.Subscribe(context.Background(), nil,
+		SubscriptionConfig{ReconnectDelay: 10 * time.Millisecond, MaxReconnectDelay: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	for _, want := range []float64{1, 2} {
+		select {
+		case set := <-sub.Updates():
+			score, ok := set.Score(dst, "fp", "throughput")
+			assert.True(t, ok)
+			assert.Equal(t, want, score)
+		case <-time.After(time.Second):
+			t.Fatalf("no update with score %v", want)
+		}
+	}
+
+	sub.Close()
+	_, open := <-sub.Updates()
+	assert.False(t, open)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&connections))
+}
@@ -1,9 +1,14 @@
 package tracers
 
 import (
+	"context"
+	"errors"
 	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
 	"github.com/scionproto/scion/go/lib/addr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net"
 	path_oracle_client "oclient"
@@ -11,6 +16,33 @@ import (
 	"time"
 )
 
+const tracerName = "oclient/tracers"
+
+// flusher is implemented by reporters queueing reports for delivery in the background.
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// unwrapper is implemented by reporters passing reports on to another reporter, e.g. after limiting or
+// anonymizing them.
+type unwrapper interface {
+	Unwrap() path_oracle_client.StatsReporter
+}
+
+// queued reports whether reporter queues reports for delivery in the background, so submitting a report does not
+// block on the path oracle. Reporters passing reports on, which forward Flush regardless, queue if the reporter they
+// pass reports on to does.
+func queued(reporter path_oracle_client.StatsReporter) bool {
+	for {
+		u, ok := reporter.(unwrapper)
+		if !ok {
+			_, ok := reporter.(flusher)
+			return ok
+		}
+		reporter = u.Unwrap()
+	}
+}
+
 type BandwidthConnectionTracer struct {
 	lock            sync.Mutex
 	logger          *zap.SugaredLogger
@@ -25,7 +57,8 @@ type BandwidthConnectionTracer struct {
 	local, remote pan.UDPAddr
 
 	intervalTicker *time.Ticker
-	oracleClient   path_oracle_client.OracleClient
+	oracleClient   *path_oracle_client.OracleClient
+	reporter       path_oracle_client.StatsReporter
 }
 
 func (b *BandwidthConnectionTracer) SetPathChan(pathC chan *pan.Path) {
@@ -44,7 +77,6 @@ func (b *BandwidthConnectionTracer) StartedConnection(local, remote net.Addr, sr
 	b.lifetimeStats.begin = b.intervalStats.begin
 	b.lifetimeStats.bytesSent = 0
 
-	b.oracleClient = path_oracle_client.NewOracleClient()
 	if b.reportingConfig.ReportingInterval > 0 {
 		b.intervalTicker = time.NewTicker(b.reportingConfig.ReportingInterval)
 		go func() {
@@ -170,32 +202,60 @@ func (b *BandwidthConnectionTracer) FinishInterval(trigger string, async bool) {
 	dur := st.end.Sub(st.begin)
 	b.intervalStats = intervalStats{begin: now, fingerprint: st.fingerprint}
 	b.lock.Unlock()
+	st.srcIA, st.dstIA = addr.IA(b.local.IA), addr.IA(b.remote.IA)
+
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "BandwidthConnectionTracer.FinishInterval",
+		trace.WithAttributes(
+			attribute.String("interval.trigger", trigger),
+			attribute.Float64("interval.duration_s", dur.Seconds()),
+			attribute.Int64("interval.bytes_sent", int64(st.bytesSent)),
+			attribute.Float64("report.throughput", st.Throughput()),
+			path_oracle_client.FingerprintKey.String(st.fingerprint),
+			path_oracle_client.DstIAKey.String(b.remote.IA.String()),
+		))
+	defer span.End()
 
 	b.csvStatsWriter.OnIntervalElapsed(st)
 	if dur < b.reportingConfig.MinIntervalForReport {
+		span.SetAttributes(attribute.Bool("interval.reported", false))
 		log.Debugw("skipping report because of report dur < min interval dur",
 			"report_dur (s)", dur.Seconds(), "min_dur (s)", b.reportingConfig.MinIntervalForReport.Seconds())
 		return
 	}
 
+	span.SetAttributes(attribute.Bool("interval.reported", true))
 	report := st.ToOracleReport()
-	report.DstIA = addr.IA(b.remote.IA)
-	report.SrcIA = addr.IA(b.local.IA)
 
-	wg := sync.WaitGroup{}
-	wg.Add(1)
-
-	go func(log *zap.SugaredLogger, wg *sync.WaitGroup) {
-		err := b.oracleClient.ReportStats(report)
-		if err != nil {
-			log.Warnw("error reporting stats to path oracle", "error", err, "trigger", trigger)
+	submit := func() {
+		ctx, cancel := b.reportContext(ctx)
+		defer cancel()
+		err := b.reporter.ReportStatsContext(ctx, report)
+		if errors.Is(err, path_oracle_client.ErrReportSuppressed) {
+			log.Debugw("report suppressed", "reason", err)
+		} else if err != nil {
+			log.Warnw("error reporting stats to path oracle", "error", err, "oracle_degraded", b.oracleDegraded())
 		} else {
-			log.Infow("successfully reported stats to oracle", "report", report, "trigger", trigger)
+			log.Infow("successfully reported stats to oracle", "report", report)
 		}
-		wg.Done()
-	}(log, &wg)
+	}
+
+	// queueing reporters return immediately, there is no need to spawn a goroutine per report
+	if queued(b.reporter) || !async {
+		submit()
+		return
+	}
+	go submit()
+}
+
+func (b *BandwidthConnectionTracer) oracleDegraded() bool {
+	return b.oracleClient != nil && b.oracleClient.Degraded()
+}
 
-	if !async {
-		wg.Wait()
+// reportContext bounds the submission of a single report by ReportTimeout. parent carries the span of the
+// interval the report belongs to.
+func (b *BandwidthConnectionTracer) reportContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if b.reportingConfig.ReportTimeout > 0 {
+		return context.WithTimeout(parent, b.reportingConfig.ReportTimeout)
 	}
+	return context.WithCancel(parent)
 }
@@ -0,0 +1,86 @@
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	path_oracle_client "oclient"
+	"oclient/privacy"
+	"oclient/reporting"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingReporter counts the reports it receives.
+type countingReporter struct {
+	mutex    sync.Mutex
+	reported int
+}
+
+func (r *countingReporter) ReportStatsContext(ctx context.Context, report oracle.Report) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.reported++
+	return nil
+}
+
+func (r *countingReporter) count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.reported
+}
+
+// senderChain wraps next like the quic_sender: queued, anonymized and limited.
+func senderChain(next path_oracle_client.StatsReporter, queue bool) path_oracle_client.StatsReporter {
+	if queue {
+		next = reporting.NewReporter(next, reporting.DefaultReporterConfig, zap.NewNop().Sugar())
+	}
+	next = privacy.NewReporter(next, privacy.NewAnonymizer(privacy.Config{DurationBucket: 1}))
+	return reporting.NewLimiter(next, reporting.LimiterConfig{})
+}
+
+func TestQueuedLooksThroughSenderChain(t *testing.T) {
+	assert.True(t, queued(senderChain(&countingReporter{}, true)))
+	assert.False(t, queued(senderChain(&countingReporter{}, false)))
+	assert.False(t, queued(&countingReporter{}))
+}
+
+func TestSenderChainFlushesQueue(t *testing.T) {
+	next := &countingReporter{}
+	chain := senderChain(next, true)
+	for i := 0; i < 3; i++ {
+		report := oracle.Report{PathFp: "fp", DstIA: addr.IA{I: 1, A: 13}, Properties: oracle.MonitoredProperties{}}
+		require.NoError(t, chain.ReportStatsContext(context.Background(), report))
+	}
+	require.Implements(t, (*flusher)(nil), chain)
+	require.NoError(t, chain.(flusher).Flush(context.Background()))
+	assert.Equal(t, 3, next.count())
+}
+
+func TestIntervalFileAppliesSrcIAMode(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "intervals.csv")
+	writer := New(CsvWritingConfig{IntervalFile: file, SrcIA: privacy.SrcIACoarsen}, zap.NewNop().Sugar())
+	writer.OnIntervalElapsed(intervalStats{
+		begin: time.Unix(0, 0),
+		end:   time.Unix(1, 0),
+		srcIA: addr.IA{I: 1, A: 0xff0000000110},
+		dstIA: addr.IA{I: 2, A: 0xff0000000220},
+	})
+	writer.Close()
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, addr.IA{I: 1}.String(), rows[1][7])
+	assert.Equal(t, "2-ff00:0:220", rows[1][8])
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/netsec-ethz/scion-apps/pkg/pan"
 	"go.uber.org/zap"
 	"net"
+	"oclient"
 )
 
 type BandwidthTracer struct {
@@ -14,6 +15,10 @@ type BandwidthTracer struct {
 	// and start a new sample.
 	ReportingConfig  ReportingConfig
 	CsvWritingConfig CsvWritingConfig
+	// OracleClient is shared by all connections to report their stats.
+	OracleClient *oclient.OracleClient
+	// Reporter optionally replaces the OracleClient as recipient of reports, e.g. to spool them.
+	Reporter oclient.StatsReporter
 
 	PathChan chan *pan.Path
 }
@@ -22,7 +27,12 @@ func (t BandwidthTracer) TracerForConnection(ctx context.Context, p logging.Pers
 	ct := &BandwidthConnectionTracer{
 		reportingConfig: t.ReportingConfig,
 		csvStatsWriter:  New(t.CsvWritingConfig, t.Logger),
+		oracleClient:    t.OracleClient,
+		reporter:        t.Reporter,
 		logger:          t.Logger.With("odcid", odcid)}
+	if ct.reporter == nil {
+		ct.reporter = t.OracleClient
+	}
 	ct.SetPathChan(t.PathChan)
 	return ct
 }
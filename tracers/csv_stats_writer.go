@@ -7,6 +7,8 @@ import (
 	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/scionproto/scion/go/lib/addr"
 	"go.uber.org/zap"
+	"oclient/privacy"
+	"oclient/reporting"
 	"os"
 	"strings"
 	"time"
@@ -14,7 +16,8 @@ import (
 
 const timeFormat = "2006-01-02T15:04:05-0700"
 
-var intervalStatsCsvHeader = []string{"begin", "end", "begin_unx", "end_unx", "fingerprint", "bytes_sent", "throughput"}
+var intervalStatsCsvHeader = []string{"begin", "end", "begin_unx", "end_unx", "fingerprint", "bytes_sent", "throughput",
+	"src_ia", "dst_ia"}
 
 type intervalStats struct {
 	begin, end  time.Time
@@ -22,9 +25,11 @@ type intervalStats struct {
 	fingerprint string
 	closeReason string
 	reported    bool
+	// srcIA and dstIA are the endpoints of the connection
+	srcIA, dstIA addr.IA
 }
 
-func (i intervalStats) ToCsvRow() []string {
+func (i intervalStats) ToCsvRow(srcIA privacy.SrcIAMode) []string {
 	return []string{
 		i.begin.Format(timeFormat),
 		i.end.Format(timeFormat),
@@ -33,26 +38,21 @@ func (i intervalStats) ToCsvRow() []string {
 		i.fingerprint,
 		fmt.Sprintf("%d", i.bytesSent),
 		fmt.Sprintf("%.0f", i.Throughput()),
+		srcIA.Apply(i.srcIA).String(),
+		i.dstIA.String(),
 	}
 }
 
 func (i intervalStats) ToOracleReport() oracle.Report {
-	return oracle.Report{
-		Metadata: oracle.Metadata{
-			Application: "quic_sender",
-			Duration:    i.end.Sub(i.begin).Seconds(),
-			Properties: oracle.MetadataProperties{
-				"protocols":             []string{"SCION", "UDP", "QUIC"},
-				"taps-capacity-profile": "capacity-seeking",
-			},
-		},
-		Properties: oracle.MonitoredProperties{
-			"throughput": i.Throughput(),
-		},
-		SrcIA:  addr.IA{},
-		DstIA:  addr.IA{},
-		PathFp: oracle.PathFingerprint(i.fingerprint),
-	}
+	return reporting.Interval{
+		Begin:       i.begin,
+		End:         i.end,
+		Fingerprint: oracle.PathFingerprint(i.fingerprint),
+		BytesSent:   uint64(i.bytesSent),
+		Throughput:  i.Throughput(),
+		SrcIA:       i.srcIA,
+		DstIA:       i.dstIA,
+	}.Report("quic_sender")
 }
 
 func (i intervalStats) Throughput() float64 {
@@ -84,11 +84,14 @@ func (i lifetimeStats) Throughput() float64 {
 
 type CsvWritingConfig struct {
 	SummaryFile, IntervalFile string
+	// SrcIA decides how much of the source IA is written to the interval file.
+	SrcIA privacy.SrcIAMode
 }
 
 type CsvStatsWriter struct {
 	summaryFile, intervalFile     *os.File
 	summaryWriter, intervalWriter *csv.Writer
+	srcIA                         privacy.SrcIAMode
 }
 
 func New(config CsvWritingConfig, logger *zap.SugaredLogger) CsvStatsWriter {
@@ -108,7 +111,7 @@ func New(config CsvWritingConfig, logger *zap.SugaredLogger) CsvStatsWriter {
 		logger.Warnw("could not open interval file", "filename", config.IntervalFile, "error", err)
 	}
 
-	c := CsvStatsWriter{summaryFile: sF, intervalFile: iF}
+	c := CsvStatsWriter{summaryFile: sF, intervalFile: iF, srcIA: config.SrcIA}
 	if c.summaryFile != nil {
 		c.summaryWriter = csv.NewWriter(c.summaryFile)
 	}
@@ -123,7 +126,7 @@ func (c *CsvStatsWriter) OnIntervalElapsed(stats intervalStats) {
 	if c.intervalWriter == nil {
 		return
 	}
-	c.intervalWriter.Write(stats.ToCsvRow())
+	c.intervalWriter.Write(stats.ToCsvRow(c.srcIA))
 }
 
 func (c *CsvStatsWriter) OnConnectionClose(stats lifetimeStats) {
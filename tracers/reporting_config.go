@@ -12,4 +12,6 @@ type ReportingConfig struct {
 	ReportingInterval time.Duration
 	// MinIntervalForReport allows to filter out reports representing stats of too little time.
 	MinIntervalForReport time.Duration
+	// ReportTimeout bounds the time spent submitting a single report to the oracle. 0 to wait indefinitely.
+	ReportTimeout time.Duration
 }
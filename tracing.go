@@ -0,0 +1,111 @@
+package oclient
+
+import (
+	"context"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/clemens97/scion-path-oracle/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sort"
+)
+
+// tracerName identifies the spans of the client. Spans are recorded by the global tracer provider, hence they
+// are discarded unless the application installed one, e.g. by otel.SetTracerProvider.
+const tracerName = "oclient"
+
+// Attributes shared by the spans of the client, the selectors and the tracers.
+const (
+	// FingerprintKey is the fingerprint of the path a span is about.
+	FingerprintKey = attribute.Key("path.fingerprint")
+	// ScoreKey is the score of the path a span is about.
+	ScoreKey = attribute.Key("path.score")
+	// DstIAKey is the destination of the paths a span is about.
+	DstIAKey = attribute.Key("path.dst_ia")
+	// PathCountKey is the number of paths a span is about.
+	PathCountKey = attribute.Key("path.count")
+)
+
+// EndSpan records err, if any, on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// queryAttributes describes the destinations and services of a scoring query.
+func queryAttributes(query server.ScoringQuery) []attribute.KeyValue {
+	dsts := make([]string, 0, len(query.Queries))
+	for dst, svcs := range query.Queries {
+		dsts = append(dsts, fmt.Sprintf("%s%v", dst, svcs))
+	}
+	sort.Strings(dsts)
+	return []attribute.KeyValue{attribute.StringSlice("oracle.query", dsts)}
+}
+
+// traceScores adds an event per scored path to span.
+func traceScores(span trace.Span, res server.ScoringResponse) {
+	if !span.IsRecording() {
+		return
+	}
+	paths := 0
+	for dst, fpScores := range res {
+		for _, s := range fpScores {
+			attrs := []attribute.KeyValue{DstIAKey.String(dst.String()), FingerprintKey.String(string(s.Fingerprint))}
+			for svc, score := range s.Scores {
+				attrs = append(attrs, attribute.Float64("score."+svc, score))
+			}
+			span.AddEvent("score", trace.WithAttributes(attrs...))
+			paths++
+		}
+	}
+	span.SetAttributes(PathCountKey.Int(paths))
+}
+
+// reportAttributes describes a report, including its numeric properties.
+func reportAttributes(report oracle.Report) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		DstIAKey.String(report.DstIA.String()),
+		FingerprintKey.String(string(report.PathFp)),
+	}
+	for name, value := range report.Properties {
+		if f, ok := value.(float64); ok {
+			attrs = append(attrs, attribute.Float64("report."+name, f))
+		}
+	}
+	return attrs
+}
+
+// traceRequest starts a client span for the request to the endpoint and propagates its context in the headers.
+func traceRequest(ep *endpoint, operation string, req *http.Request) trace.Span {
+	ctx, span := otel.Tracer(tracerName).Start(req.Context(), "oracle "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("oracle.endpoint", ep.baseURL.String()),
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.String()),
+		))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return span
+}
+
+// endRequest records the outcome of a request on its span and ends it.
+func endRequest(span trace.Span, res *http.Response, err error) {
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.status_code", res.StatusCode))
+		if res.StatusCode >= 500 {
+			span.SetStatus(codes.Error, res.Status)
+		}
+	}
+	EndSpan(span, err)
+}
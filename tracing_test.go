@@ -0,0 +1,99 @@
+package oclient
+
+import (
+	"context"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordSpans installs a tracer provider recording all spans for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestFetchScoresIsTraced(t *testing.T) {
+	recorder := recordSpans(t)
+	var requests int32
+	srv := scoringServer("fp", 42, http.StatusOK, &requests)
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	_, err = c.FetchScores(federationQuery)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	request, fetch := spans[0], spans[1]
+	assert.Equal(t, "oracle scores", request.Name())
+	assert.Equal(t, "OracleClient.FetchScores", fetch.Name())
+	assert.Equal(t, fetch.SpanContext().SpanID(), request.Parent().SpanID())
+	assert.Equal(t, int64(http.StatusOK), spanAttributes(request)["http.status_code"].AsInt64())
+	assert.Equal(t, int64(1), spanAttributes(fetch)[PathCountKey].AsInt64())
+
+	require.Len(t, fetch.Events(), 1)
+	event := make(map[attribute.Key]attribute.Value)
+	for _, kv := range fetch.Events()[0].Attributes {
+		event[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "fp", event[FingerprintKey].AsString())
+	assert.Equal(t, 42., event["score.throughput"].AsFloat64())
+}
+
+func TestReportStatsIsTraced(t *testing.T) {
+	recorder := recordSpans(t)
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport))
+	require.NoError(t, err)
+	report := oracle.Report{
+		DstIA:      addr.IA{I: 1, A: 13},
+		PathFp:     "fp",
+		Properties: oracle.MonitoredProperties{"throughput": 3.5},
+	}
+	err = c.ReportStatsContext(context.Background(), report)
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	reportSpan := spans[1]
+	assert.Equal(t, "OracleClient.ReportStats", reportSpan.Name())
+	assert.Equal(t, codes.Error, reportSpan.Status().Code)
+	attrs := spanAttributes(reportSpan)
+	assert.Equal(t, "fp", attrs[FingerprintKey].AsString())
+	assert.Equal(t, "1-13", attrs[DstIAKey].AsString())
+	assert.Equal(t, 3.5, attrs["report.throughput"].AsFloat64())
+	assert.Contains(t, traceparent, spans[0].SpanContext().SpanID().String())
+}
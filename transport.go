@@ -0,0 +1,151 @@
+package oclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/netsec-ethz/scion-apps/pkg/shttp"
+	"github.com/scionproto/scion/go/lib/addr"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Network selects how requests reach the path oracle.
+type Network int
+
+const (
+	// NetworkSCION sends requests via HTTP over SCION/QUIC.
+	NetworkSCION Network = iota
+	// NetworkIP sends requests via HTTP over TCP/IP.
+	NetworkIP
+	// NetworkAuto uses SCION if the host of the base URL is a SCION address and IP otherwise.
+	NetworkAuto
+)
+
+func (n Network) String() string {
+	switch n {
+	case NetworkSCION:
+		return "scion"
+	case NetworkIP:
+		return "ip"
+	case NetworkAuto:
+		return "auto"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseNetwork parses the name of a Network as returned by its String method.
+func ParseNetwork(s string) (Network, error) {
+	for _, n := range []Network{NetworkSCION, NetworkIP, NetworkAuto} {
+		if strings.EqualFold(s, n.String()) {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown network %q, expected one of scion, ip or auto", s)
+}
+
+// parseBaseURL parses the location of the path oracle. SCION addresses, e.g.
+// http://1-ff00:0:110,[127.0.0.1]:8080/api, are mangled into a valid URL host.
+func parseBaseURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, errors.New("no path oracle base URL configured")
+	}
+	baseURL, err := url.Parse(shttp.MangleSCIONAddrURL(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid path oracle base URL: %w", err)
+	}
+	if baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, fmt.Errorf("path oracle base URL %q lacks scheme or host", raw)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("path oracle base URL %q has unsupported scheme %q", raw, baseURL.Scheme)
+	}
+	baseURL.Path = strings.TrimSuffix(baseURL.Path, "/")
+	baseURL.RawPath = strings.TrimSuffix(baseURL.RawPath, "/")
+	return baseURL, nil
+}
+
+// isSCIONHost reports whether the host of u is a (mangled) SCION address.
+func isSCIONHost(u *url.URL) bool {
+	host := u.Hostname()
+	i := strings.Index(host, ",")
+	if i < 0 {
+		return false
+	}
+	_, err := addr.IAFromString(host[:i])
+	return err == nil
+}
+
+// newTransport creates the http.RoundTripper for network, trusting tlsConfig for https.
+func newTransport(network Network, baseURL *url.URL, tlsConfig *tls.Config) (http.RoundTripper, error) {
+	if network == NetworkAuto {
+		network = NetworkIP
+		if isSCIONHost(baseURL) {
+			network = NetworkSCION
+		}
+	}
+
+	var transport *http.Transport
+	switch network {
+	case NetworkSCION:
+		if tlsConfig == nil {
+			return shttp.DefaultTransport, nil
+		}
+		transport = shttp.DefaultTransport.Clone()
+	case NetworkIP:
+		if isSCIONHost(baseURL) {
+			return nil, fmt.Errorf("path oracle %s has a SCION address, but network %s is configured", baseURL.Host, network)
+		}
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	default:
+		return nil, fmt.Errorf("unknown network %d", network)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// buildTLSConfig combines the TLS configuration, CA certificates and client certificate configured in o.
+// It returns nil if none of them is configured.
+func (o *options) buildTLSConfig() (*tls.Config, error) {
+	if o.caFile == "" && o.certFile == "" {
+		return o.tlsConfig, nil
+	}
+	tlsConfig := &tls.Config{}
+	if o.tlsConfig != nil {
+		tlsConfig = o.tlsConfig.Clone()
+	}
+	if o.caFile != "" {
+		pool, err := loadCertPool(o.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if o.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	return tlsConfig, nil
+}
+
+// loadCertPool reads PEM encoded CA certificates from file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificates: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,69 @@
+package oclient
+
+import (
+	"encoding/pem"
+	"github.com/clemens97/scion-path-oracle/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSCIONBaseURL(t *testing.T) {
+	baseURL, err := parseBaseURL("http://1-ff00:0:110,[127.0.0.1]:8080/api/")
+	require.NoError(t, err)
+	assert.Equal(t, "[1-ff00:0:110,127.0.0.1]:8080", baseURL.Host)
+	assert.Equal(t, "/api", baseURL.Path)
+	assert.True(t, isSCIONHost(baseURL))
+
+	baseURL, err = parseBaseURL("https://oracle.local:8443")
+	require.NoError(t, err)
+	assert.False(t, isSCIONHost(baseURL))
+
+	_, err = parseBaseURL("ftp://oracle.local")
+	assert.Error(t, err)
+}
+
+func TestNetworkSelection(t *testing.T) {
+	c, err := NewOracleClient(WithBaseURL("http://127.0.0.1:8080"), WithNetwork(NetworkAuto))
+	require.NoError(t, err)
+	assert.IsType(t, &http.Transport{}, c.endpoints[0].httpc.Transport)
+	assert.NotNil(t, c.endpoints[0].httpc.Transport.(*http.Transport).Proxy)
+
+	_, err = NewOracleClient(WithBaseURL("http://1-ff00:0:110,[127.0.0.1]:8080"), WithNetwork(NetworkIP))
+	assert.Error(t, err)
+
+	n, err := ParseNetwork("SCION")
+	assert.NoError(t, err)
+	assert.Equal(t, NetworkSCION, n)
+	_, err = ParseNetwork("carrier-pigeon")
+	assert.Error(t, err)
+}
+
+func TestHTTPSWithCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, pemCert, 0600))
+
+	query := server.ScoringQuery{}
+	untrusting, err := NewOracleClient(WithBaseURL(srv.URL), WithNetwork(NetworkIP))
+	require.NoError(t, err)
+	_, err = untrusting.FetchScores(query)
+	assert.Error(t, err)
+
+	trusting, err := NewOracleClient(WithBaseURL(srv.URL), WithNetwork(NetworkIP), WithCAFile(caFile))
+	require.NoError(t, err)
+	_, err = trusting.FetchScores(query)
+	assert.NoError(t, err)
+
+	_, err = NewOracleClient(WithBaseURL(srv.URL), WithCAFile(filepath.Join(t.TempDir(), "missing.pem")))
+	assert.Error(t, err)
+}
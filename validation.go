@@ -0,0 +1,183 @@
+package oclient
+
+import (
+	"errors"
+	"fmt"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"math"
+	"oclient/internal/props"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidReport indicates that a report violates the ReportSchema of the client and was not submitted.
+var ErrInvalidReport = errors.New("report violates schema")
+
+// PropertyType is the type of the value of a monitored property.
+type PropertyType int
+
+const (
+	PropertyNumber PropertyType = iota
+	PropertyString
+	PropertyBool
+)
+
+func (t PropertyType) String() string {
+	switch t {
+	case PropertyNumber:
+		return "number"
+	case PropertyString:
+		return "string"
+	case PropertyBool:
+		return "bool"
+	}
+	return fmt.Sprintf("PropertyType(%d)", int(t))
+}
+
+// PropertySchema describes the valid values of a monitored property.
+type PropertySchema struct {
+	Type PropertyType
+	// Min and Max bound numbers, if Min < Max. NaN and infinite numbers are always invalid.
+	Min, Max float64
+	// Unit documents the unit numbers are measured in, e.g. bytes/s.
+	Unit string
+	// Required properties have to be present in every report.
+	Required bool
+}
+
+// ReportSchema describes valid reports. Reports always need a destination and a path fingerprint.
+type ReportSchema struct {
+	// Properties are the known monitored properties.
+	Properties map[string]PropertySchema
+	// RejectUnknown rejects reports with monitored properties missing in Properties.
+	RejectUnknown bool
+	// RequireApplication rejects reports without the application they were measured by.
+	RequireApplication bool
+	// MinDuration is the duration in seconds reports have to exceed.
+	MinDuration float64
+	// RequiredMetadata are the metadata properties which have to be present in every report.
+	RequiredMetadata []string
+}
+
+// DefaultReportSchema requires a finite, non-negative throughput measured over a positive duration, and bounds
+// the other properties reported by common applications.
+var DefaultReportSchema = ReportSchema{
+	Properties: map[string]PropertySchema{
+		"throughput": {Type: PropertyNumber, Min: 0, Max: math.Inf(1), Unit: "bytes/s", Required: true},
+		"latency":    {Type: PropertyNumber, Min: 0, Max: math.Inf(1), Unit: "ms"},
+		"loss":       {Type: PropertyNumber, Min: 0, Max: 1, Unit: "ratio"},
+	},
+	RequireApplication: true,
+}
+
+// InvalidReportError lists the violations of a ReportSchema by a report. It matches ErrInvalidReport.
+type InvalidReportError struct {
+	Reasons []string
+}
+
+func (e *InvalidReportError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidReport, strings.Join(e.Reasons, "; "))
+}
+
+func (e *InvalidReportError) Is(target error) bool {
+	return target == ErrInvalidReport
+}
+
+// Validate returns an InvalidReportError if report violates the schema.
+func (s *ReportSchema) Validate(report oracle.Report) error {
+	var reasons []string
+	if report.DstIA.IsZero() {
+		reasons = append(reasons, "missing destination IA")
+	}
+	if report.PathFp == "" {
+		reasons = append(reasons, "missing path fingerprint")
+	}
+	if s.RequireApplication && report.Metadata.Application == "" {
+		reasons = append(reasons, "missing application")
+	}
+	if d := report.Metadata.Duration; math.IsNaN(d) || math.IsInf(d, 0) || d <= s.MinDuration {
+		reasons = append(reasons, fmt.Sprintf("duration %g s does not exceed %g s", d, s.MinDuration))
+	}
+	for _, name := range s.RequiredMetadata {
+		if _, ok := report.Metadata.Properties[name]; !ok {
+			reasons = append(reasons, fmt.Sprintf("missing metadata property %s", name))
+		}
+	}
+
+	for name, schema := range s.Properties {
+		if _, ok := report.Properties[name]; !ok && schema.Required {
+			reasons = append(reasons, fmt.Sprintf("missing property %s", name))
+		}
+	}
+	names := make([]string, 0, len(report.Properties))
+	for name := range report.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schema, ok := s.Properties[name]
+		if !ok {
+			if s.RejectUnknown {
+				reasons = append(reasons, fmt.Sprintf("unknown property %s", name))
+			}
+			continue
+		}
+		if reason := schema.check(report.Properties[name]); reason != "" {
+			reasons = append(reasons, fmt.Sprintf("property %s %s", name, reason))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &InvalidReportError{Reasons: reasons}
+	}
+	return nil
+}
+
+// check returns why value is invalid, or an empty string.
+func (p PropertySchema) check(value interface{}) string {
+	switch p.Type {
+	case PropertyString:
+		if _, ok := value.(string); !ok {
+			return fmt.Sprintf("is %T, not a string", value)
+		}
+	case PropertyBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("is %T, not a bool", value)
+		}
+	case PropertyNumber:
+		f, ok := props.Number(value)
+		switch {
+		case !ok:
+			return fmt.Sprintf("is %T, not a number", value)
+		case math.IsNaN(f) || math.IsInf(f, 0):
+			return fmt.Sprintf("is %g", f)
+		case p.Min < p.Max && (f < p.Min || f > p.Max):
+			return fmt.Sprintf("%g %s is out of [%g, %g]", f, p.Unit, p.Min, p.Max)
+		}
+	}
+	return ""
+}
+
+// Quarantine keeps reports rejected by the validation of the client for inspection.
+type Quarantine interface {
+	Quarantine(report oracle.Report, reason error) error
+}
+
+// validate checks report against the schema configured by WithValidation. Invalid reports are quarantined.
+func (c *OracleClient) validate(report oracle.Report) error {
+	if c.schema == nil {
+		return nil
+	}
+	err := c.schema.Validate(report)
+	if err == nil {
+		return nil
+	}
+	c.metrics.ReportDropped(DropInvalid)
+	if c.quarantine != nil {
+		if qErr := c.quarantine.Quarantine(report, err); qErr != nil {
+			c.logger.Warnw("error quarantining invalid report", "error", qErr, "reason", err)
+		}
+	}
+	c.logger.Infow("not submitting invalid report", "reason", err, "dst", report.DstIA, "fingerprint", report.PathFp)
+	return err
+}
@@ -0,0 +1,109 @@
+package oclient
+
+import (
+	"context"
+	"errors"
+	oracle "github.com/clemens97/scion-path-oracle"
+	"github.com/scionproto/scion/go/lib/addr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func validReport() oracle.Report {
+	return oracle.Report{
+		Metadata:   oracle.Metadata{Application: "quic_sender", Duration: 5},
+		Properties: oracle.MonitoredProperties{"throughput": 1e6, "loss": 0.1},
+		DstIA:      addr.IA{I: 1, A: 13},
+		PathFp:     "a b",
+	}
+}
+
+func TestValidateReport(t *testing.T) {
+	schema := DefaultReportSchema
+	assert.NoError(t, schema.Validate(validReport()))
+
+	for name, tc := range map[string]struct {
+		modify func(r *oracle.Report)
+		reason string
+	}{
+		"nan":         {func(r *oracle.Report) { r.Properties["throughput"] = math.NaN() }, "property throughput is NaN"},
+		"inf":         {func(r *oracle.Report) { r.Properties["throughput"] = math.Inf(1) }, "property throughput is +Inf"},
+		"range":       {func(r *oracle.Report) { r.Properties["loss"] = 1.5 }, "property loss 1.5 ratio is out of [0, 1]"},
+		"type":        {func(r *oracle.Report) { r.Properties["loss"] = "none" }, "property loss is string, not a number"},
+		"required":    {func(r *oracle.Report) { delete(r.Properties, "throughput") }, "missing property throughput"},
+		"fingerprint": {func(r *oracle.Report) { r.PathFp = "" }, "missing path fingerprint"},
+		"destination": {func(r *oracle.Report) { r.DstIA = addr.IA{} }, "missing destination IA"},
+		"application": {func(r *oracle.Report) { r.Metadata.Application = "" }, "missing application"},
+		"duration":    {func(r *oracle.Report) { r.Metadata.Duration = 0 }, "duration 0 s does not exceed 0 s"},
+	} {
+		r := validReport()
+		tc.modify(&r)
+		err := schema.Validate(r)
+		require.Error(t, err, name)
+		assert.True(t, errors.Is(err, ErrInvalidReport), name)
+		var invalid *InvalidReportError
+		require.True(t, errors.As(err, &invalid), name)
+		assert.Equal(t, []string{tc.reason}, invalid.Reasons, name)
+	}
+
+	schema.RejectUnknown = true
+	schema.RequiredMetadata = []string{"protocols"}
+	r := validReport()
+	r.Properties["jitter"] = 1.
+	var invalid *InvalidReportError
+	require.True(t, errors.As(schema.Validate(r), &invalid))
+	assert.Equal(t, []string{"missing metadata property protocols", "unknown property jitter"}, invalid.Reasons)
+}
+
+type recordingQuarantine struct {
+	reports []oracle.Report
+	reasons []error
+}
+
+func (q *recordingQuarantine) Quarantine(report oracle.Report, reason error) error {
+	q.reports = append(q.reports, report)
+	q.reasons = append(q.reasons, reason)
+	return nil
+}
+
+func TestInvalidReportsAreQuarantined(t *testing.T) {
+	var requests int32
+	var batchSize int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == defaultPaths.Batch {
+			var batch []batchReport
+			require.NoError(t, decode(&http.Response{Header: r.Header, Body: r.Body}, &batch))
+			batchSize = len(batch)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	quarantine := &recordingQuarantine{}
+	c, err := NewOracleClient(WithBaseURL(srv.URL), WithTransport(http.DefaultTransport),
+		WithValidation(DefaultReportSchema, quarantine))
+	require.NoError(t, err)
+
+	invalid := validReport()
+	invalid.Properties["throughput"] = math.NaN()
+	err = c.ReportStats(invalid)
+	assert.True(t, errors.Is(err, ErrInvalidReport))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+	require.Len(t, quarantine.reports, 1)
+	assert.Equal(t, invalid.PathFp, quarantine.reports[0].PathFp)
+
+	err = c.ReportStatsBatchContext(context.Background(), []oracle.Report{validReport(), invalid})
+	require.NoError(t, err)
+	assert.Equal(t, 1, batchSize)
+	assert.Len(t, quarantine.reports, 2)
+
+	err = c.ReportStatsBatchContext(context.Background(), []oracle.Report{invalid})
+	assert.True(t, errors.Is(err, ErrInvalidReport))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}